@@ -0,0 +1,145 @@
+/*
+ * Copyright 1999-2018 Alibaba Group.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Compression selects the compression applied to a packed archive.
+type Compression string
+
+// Supported compressions. Gzip is implemented with the standard library.
+// Zstd is declared but not implemented, since this repo doesn't currently
+// vendor a zstd implementation -- Compression.validate rejects it (and any
+// other unrecognized value) rather than silently falling back to
+// CompressionNone.
+const (
+	CompressionNone Compression = ""
+	CompressionGzip Compression = "gzip"
+	CompressionZstd Compression = "zstd"
+)
+
+// knownCompressions is the set of Compression values PackTar/PackZip can
+// actually apply.
+var knownCompressions = map[Compression]bool{
+	CompressionNone: true,
+	CompressionGzip: true,
+}
+
+// validate reports an error if c isn't a Compression PackTar/PackZip know
+// how to apply, e.g. CompressionZstd until this package vendors a zstd
+// implementation.
+func (c Compression) validate() error {
+	if !knownCompressions[c] {
+		return fmt.Errorf("pack archive error, unsupported compression %q", c)
+	}
+	return nil
+}
+
+// PackOptions configures PackTar/PackZip.
+type PackOptions struct {
+	// Compression selects the compression applied to the packed archive.
+	Compression Compression
+	// DigestAlgorithm is used to compute each entry's Digest for the
+	// returned manifest. Defaults to AlgorithmSHA256 when empty.
+	DigestAlgorithm Algorithm
+}
+
+// UnpackOptions configures UnpackTar/UnpackZip.
+type UnpackOptions struct {
+	// Compression is not read by UnpackTar or UnpackZip: UnpackTar
+	// detects a gzip member by its magic bytes, and each zip entry
+	// records its own compression method.
+	Compression Compression
+}
+
+// ManifestEntry describes a single file packed into an archive.
+type ManifestEntry struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	Digest Digest `json:"digest"`
+}
+
+// Manifest enumerates the entries of a packed archive so a downloader can
+// verify individual files without re-hashing the whole archive.
+type Manifest struct {
+	Entries []ManifestEntry `json:"-"`
+}
+
+// Reader serializes the manifest as newline-delimited JSON, one
+// ManifestEntry per line, suitable for streaming.
+func (m Manifest) Reader() (io.Reader, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, e := range m.Entries {
+		if err := enc.Encode(e); err != nil {
+			return nil, fmt.Errorf("encode manifest entry:%s error, %v", e.Name, err)
+		}
+	}
+	return &buf, nil
+}
+
+// walkFs walks the tree rooted at root using fu.Fs (so it works the same
+// way over an OsFs or a MemFs), calling fn once per file or directory,
+// root included, in deterministic lexicographic order per directory.
+func (fu *FileUtil) walkFs(root string, fn func(path string, info os.FileInfo) error) error {
+	info, err := fu.Fs.Stat(root)
+	if err != nil {
+		return err
+	}
+	return fu.walkFsEntry(root, info, fn)
+}
+
+func (fu *FileUtil) walkFsEntry(path string, info os.FileInfo, fn func(path string, info os.FileInfo) error) error {
+	if err := fn(path, info); err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return nil
+	}
+	entries, err := fu.Fs.ReadDir(path)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := fu.walkFsEntry(filepath.Join(path, entry.Name()), entry, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// archiveSafeName validates that name is a relative path that can't
+// escape the destination directory it will be extracted into (the
+// "Zip-Slip" class of vulnerability: absolute paths or ".." components).
+func archiveSafeName(name string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(name))
+	if filepath.IsAbs(clean) {
+		return "", fmt.Errorf("unpack archive error, entry %q has an absolute path", name)
+	}
+	if clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("unpack archive error, entry %q escapes the destination directory", name)
+	}
+	return clean, nil
+}
@@ -0,0 +1,102 @@
+/*
+ * Copyright 1999-2018 Alibaba Group.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package util
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// CopyFileRange copies the n bytes of src starting at offset off to the
+// end of dst, creating dst if it doesn't already exist. It is suitable for
+// piece-level copying, where src is a larger file and only one piece's
+// worth of bytes needs to land in dst.
+//
+// On linux/amd64, when both files are backed by the real OS filesystem, this
+// uses the copy_file_range(2) fast path, which avoids copying the data
+// through userspace; everywhere else, and whenever the fast path isn't
+// available (e.g. across filesystems), it falls back to a portable
+// Seek+io.CopyN.
+func (fu *FileUtil) CopyFileRange(src string, dst string, off int64, n int64) (int64, error) {
+	if !fu.IsRegularFile(src) {
+		return 0, fmt.Errorf("copy file range:%s error, is not a regular file", src)
+	}
+	s, err := fu.OpenFile(src, os.O_RDONLY, 0666)
+	if err != nil {
+		return 0, err
+	}
+	defer s.Close()
+
+	// O_APPEND is deliberately not used here: Linux's copy_file_range(2)
+	// unconditionally fails with EBADF when the output fd has O_APPEND
+	// set, which would make the fast path below never work. Instead we
+	// seek to the current end of dst once, up front, and let the fast
+	// and portable paths both write from there.
+	d, err := fu.OpenFile(dst, os.O_WRONLY|os.O_CREATE, 0755)
+	if err != nil {
+		return 0, err
+	}
+	defer d.Close()
+
+	if _, err := d.Seek(0, io.SeekEnd); err != nil {
+		return 0, err
+	}
+
+	if sf, ok := s.(*os.File); ok {
+		if df, ok := d.(*os.File); ok {
+			written, err := copyFileRangeOS(df, sf, off, n)
+			switch err {
+			case nil:
+				return written, nil
+			case errCopyFileRangeUnsupported:
+				// Some bytes may already have been copied before the
+				// kernel gave up (e.g. EXDEV partway through); resume the
+				// remainder with the portable path below.
+				remaining := n - written
+				if remaining <= 0 {
+					return written, nil
+				}
+				portable, perr := copyFileRangePortable(s, d, off+written, remaining)
+				return written + portable, perr
+			default:
+				return written, err
+			}
+		}
+	}
+	return copyFileRangePortable(s, d, off, n)
+}
+
+// copyFileRangePortable copies the n bytes of src starting at offset off
+// to dst using a plain Seek+io.CopyN, for filesystems that don't expose
+// an OS-level range copy.
+func copyFileRangePortable(src File, dst File, off int64, n int64) (int64, error) {
+	if _, err := src.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+	written, err := io.CopyN(dst, src, n)
+	if err == io.EOF {
+		err = nil
+	}
+	return written, err
+}
+
+// CopyFileRange copies the n bytes of src starting at offset off to the
+// end of dst, creating dst if it doesn't already exist.
+func CopyFileRange(src string, dst string, off int64, n int64) (int64, error) {
+	return defaultFileUtil.CopyFileRange(src, dst, off, n)
+}
@@ -0,0 +1,35 @@
+//go:build !(linux && amd64)
+// +build !linux !amd64
+
+/*
+ * Copyright 1999-2018 Alibaba Group.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package util
+
+import (
+	"errors"
+	"os"
+)
+
+// errCopyFileRangeUnsupported signals to CopyFileRange that the OS-level
+// fast path isn't available and the portable fallback should be used.
+var errCopyFileRangeUnsupported = errors.New("copy_file_range unsupported on this platform")
+
+// copyFileRangeOS has no fast path outside linux/amd64; it always defers
+// to the portable Seek+io.CopyN fallback in CopyFileRange.
+func copyFileRangeOS(dst, src *os.File, off int64, n int64) (int64, error) {
+	return 0, errCopyFileRangeUnsupported
+}
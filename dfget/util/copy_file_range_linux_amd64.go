@@ -0,0 +1,73 @@
+//go:build linux && amd64
+// +build linux,amd64
+
+/*
+ * Copyright 1999-2018 Alibaba Group.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package util
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// sysCopyFileRange is the linux/amd64 syscall number for copy_file_range,
+// which the syscall package doesn't expose as a named constant on this
+// architecture (unlike e.g. loong64). See
+// arch/x86/entry/syscalls/syscall_64.tbl in the Linux kernel source.
+const sysCopyFileRange = 326
+
+// errCopyFileRangeUnsupported signals to CopyFileRange that the
+// copy_file_range(2) fast path isn't usable for this pair of files (e.g.
+// they live on different filesystems) and the portable fallback should be
+// used instead.
+var errCopyFileRangeUnsupported = errors.New("copy_file_range unsupported for this file pair")
+
+// copyFileRangeOS copies up to n bytes from src, starting at offset off,
+// into dst's current position using the Linux copy_file_range(2) syscall,
+// which can avoid ever copying the data through userspace.
+func copyFileRangeOS(dst, src *os.File, off int64, n int64) (int64, error) {
+	srcOff := off
+	var written int64
+	for written < n {
+		r, err := copyFileRangeSyscall(int(src.Fd()), &srcOff, int(dst.Fd()), nil, int(n-written))
+		if err != nil {
+			if err == syscall.ENOSYS || err == syscall.EXDEV || err == syscall.EINVAL {
+				return written, errCopyFileRangeUnsupported
+			}
+			return written, err
+		}
+		if r == 0 {
+			// src hit EOF before n bytes were copied.
+			break
+		}
+		written += int64(r)
+	}
+	return written, nil
+}
+
+func copyFileRangeSyscall(srcFd int, srcOff *int64, dstFd int, dstOff *int64, n int) (int, error) {
+	r, _, errno := syscall.Syscall6(sysCopyFileRange,
+		uintptr(srcFd), uintptr(unsafe.Pointer(srcOff)),
+		uintptr(dstFd), uintptr(unsafe.Pointer(dstOff)),
+		uintptr(n), 0)
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(r), nil
+}
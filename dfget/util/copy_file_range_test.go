@@ -0,0 +1,133 @@
+/*
+ * Copyright 1999-2018 Alibaba Group.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package util
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+func TestFileUtil_CopyFileContext_Cancelled(t *testing.T) {
+	fu := NewFileUtil(NewMemFs())
+	writeMemFile(t, fu, "/src", "hello world")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := fu.CopyFileContext(ctx, "/src", "/dst"); err != ctx.Err() {
+		t.Fatalf("CopyFileContext() error = %v, want %v", err, ctx.Err())
+	}
+}
+
+func TestFileUtil_AppendFile(t *testing.T) {
+	fu := NewFileUtil(NewMemFs())
+	writeMemFile(t, fu, "/src", "world")
+
+	d, err := fu.OpenFile("/dst", os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatalf("OpenFile() error = %v", err)
+	}
+	d.Write([]byte("hello "))
+	d.Close()
+
+	if _, err := fu.AppendFile("/src", "/dst"); err != nil {
+		t.Fatalf("AppendFile() error = %v", err)
+	}
+
+	got, err := fu.OpenFile("/dst", os.O_RDONLY, 0)
+	if err != nil {
+		t.Fatalf("OpenFile() error = %v", err)
+	}
+	buf := make([]byte, 32)
+	n, _ := got.Read(buf)
+	if string(buf[:n]) != "hello world" {
+		t.Fatalf("appended content = %q, want %q", buf[:n], "hello world")
+	}
+}
+
+func TestFileUtil_CopyFileRange_OsFs(t *testing.T) {
+	fu := NewFileUtil(NewOsFs())
+	dir, err := os.MkdirTemp("", "dragonfly-copy-file-range-")
+	if err != nil {
+		t.Fatalf("MkdirTemp() error = %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := dir + "/src"
+	if err := os.WriteFile(src, []byte("0123456789"), 0644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	dst := dir + "/dst"
+
+	n, err := fu.CopyFileRange(src, dst, 3, 4)
+	if err != nil {
+		t.Fatalf("CopyFileRange() error = %v", err)
+	}
+	if n != 4 {
+		t.Fatalf("CopyFileRange() = %d, want 4", n)
+	}
+
+	got, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if string(got) != "3456" {
+		t.Fatalf("copied range = %q, want %q", got, "3456")
+	}
+
+	// A second call must append after the first range rather than
+	// failing or overwriting it -- exercising this against real files is
+	// what catches the OS fast path returning EBADF on an O_APPEND fd.
+	n, err = fu.CopyFileRange(src, dst, 7, 3)
+	if err != nil {
+		t.Fatalf("CopyFileRange() second call error = %v", err)
+	}
+	if n != 3 {
+		t.Fatalf("CopyFileRange() second call = %d, want 3", n)
+	}
+	got, err = os.ReadFile(dst)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if string(got) != "3456789" {
+		t.Fatalf("copied range = %q, want %q", got, "3456789")
+	}
+}
+
+func TestFileUtil_CopyFileRange_Portable(t *testing.T) {
+	fu := NewFileUtil(NewMemFs())
+	writeMemFile(t, fu, "/src", "0123456789")
+
+	n, err := fu.CopyFileRange("/src", "/dst", 3, 4)
+	if err != nil {
+		t.Fatalf("CopyFileRange() error = %v", err)
+	}
+	if n != 4 {
+		t.Fatalf("CopyFileRange() = %d, want 4", n)
+	}
+
+	got, err := fu.OpenFile("/dst", os.O_RDONLY, 0)
+	if err != nil {
+		t.Fatalf("OpenFile() error = %v", err)
+	}
+	buf := make([]byte, 32)
+	rn, _ := got.Read(buf)
+	if string(buf[:rn]) != "3456" {
+		t.Fatalf("copied range = %q, want %q", buf[:rn], "3456")
+	}
+}
@@ -0,0 +1,161 @@
+/*
+ * Copyright 1999-2018 Alibaba Group.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package util
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+// Algorithm identifies a supported checksum algorithm by name, e.g. when
+// round-tripping through piece/task metadata or an OCI/registry digest.
+type Algorithm string
+
+// Supported algorithms. New algorithms can be added via RegisterHasher
+// without touching this package's call sites.
+const (
+	AlgorithmMD5    Algorithm = "md5"
+	AlgorithmSHA256 Algorithm = "sha256"
+	AlgorithmSHA512 Algorithm = "sha512"
+	AlgorithmCRC32C Algorithm = "crc32c"
+)
+
+// Digest is the result of hashing a file with a given Algorithm.
+type Digest struct {
+	Algorithm Algorithm
+	Hex       string
+}
+
+// String formats the digest as "algorithm:hex", the same convention used
+// by OCI/registry digests.
+func (d Digest) String() string {
+	return fmt.Sprintf("%s:%s", d.Algorithm, d.Hex)
+}
+
+// Hasher is the subset of hash.Hash the digest registry needs: any
+// standard library hash.Hash already satisfies it.
+type Hasher interface {
+	hash.Hash
+}
+
+// hasherFactories holds the registered Algorithm -> Hasher constructors.
+var hasherFactories = map[Algorithm]func() Hasher{
+	AlgorithmMD5:    func() Hasher { return md5.New() },
+	AlgorithmSHA256: func() Hasher { return sha256.New() },
+	AlgorithmSHA512: func() Hasher { return sha512.New() },
+	AlgorithmCRC32C: func() Hasher { return crc32.New(crc32.MakeTable(crc32.Castagnoli)) },
+}
+
+// RegisterHasher registers a Hasher constructor for algo, overriding any
+// existing registration. It is not safe for concurrent use with
+// ComputeDigest/ComputeDigests and is intended to be called from init().
+func RegisterHasher(algo Algorithm, newHasher func() Hasher) {
+	hasherFactories[algo] = newHasher
+}
+
+func newHasher(algo Algorithm) (Hasher, error) {
+	factory, ok := hasherFactories[algo]
+	if !ok {
+		return nil, fmt.Errorf("compute digest error, unsupported algorithm:%s", algo)
+	}
+	return factory(), nil
+}
+
+// ComputeDigest computes the Digest of the file at path using algo.
+func (fu *FileUtil) ComputeDigest(path string, algo Algorithm) (Digest, error) {
+	digests, err := fu.ComputeDigests(path, algo)
+	if err != nil {
+		return Digest{}, err
+	}
+	return digests[0], nil
+}
+
+// ComputeDigests computes the Digest of the file at path for every algo,
+// in a single pass over the file via io.MultiWriter.
+func (fu *FileUtil) ComputeDigests(path string, algos ...Algorithm) ([]Digest, error) {
+	if len(algos) == 0 {
+		return nil, fmt.Errorf("compute digest:%s error, no algorithm given", path)
+	}
+	if !fu.IsRegularFile(path) {
+		return nil, fmt.Errorf("compute digest:%s error, is not a regular file", path)
+	}
+	f, err := fu.OpenFile(path, os.O_RDONLY, 0666)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	hashers := make([]Hasher, len(algos))
+	writers := make([]io.Writer, len(algos))
+	for i, algo := range algos {
+		h, err := newHasher(algo)
+		if err != nil {
+			return nil, err
+		}
+		hashers[i] = h
+		writers[i] = h
+	}
+
+	if _, err := io.Copy(io.MultiWriter(writers...), f); err != nil {
+		return nil, fmt.Errorf("compute digest:%s error, %v", path, err)
+	}
+
+	digests := make([]Digest, len(algos))
+	for i, algo := range algos {
+		digests[i] = Digest{Algorithm: algo, Hex: fmt.Sprintf("%x", hashers[i].Sum(nil))}
+	}
+	return digests, nil
+}
+
+// MoveFileAfterCheckDigest checks that the file src matches the given
+// Digest before moving it to dst.
+func (fu *FileUtil) MoveFileAfterCheckDigest(src string, dst string, digest Digest) error {
+	if !fu.IsRegularFile(src) {
+		return fmt.Errorf("move file with digest check:%s error, is not a regular file", src)
+	}
+	d, err := fu.ComputeDigest(src, digest.Algorithm)
+	if err != nil {
+		return fmt.Errorf("move file with digest check:%s error, %v", src, err)
+	}
+	if d.Hex != digest.Hex {
+		return fmt.Errorf("move file with digest check:%s error, %s digest of source file doesn't match against the given digest value", src, digest.Algorithm)
+	}
+	return fu.MoveFile(src, dst)
+}
+
+// ComputeDigest computes the Digest of the file at path using algo.
+func ComputeDigest(path string, algo Algorithm) (Digest, error) {
+	return defaultFileUtil.ComputeDigest(path, algo)
+}
+
+// ComputeDigests computes the Digest of the file at path for every algo,
+// in a single pass over the file.
+func ComputeDigests(path string, algos ...Algorithm) ([]Digest, error) {
+	return defaultFileUtil.ComputeDigests(path, algos...)
+}
+
+// MoveFileAfterCheckDigest checks that the file src matches the given
+// Digest before moving it to dst.
+func MoveFileAfterCheckDigest(src string, dst string, digest Digest) error {
+	return defaultFileUtil.MoveFileAfterCheckDigest(src, dst, digest)
+}
@@ -0,0 +1,120 @@
+/*
+ * Copyright 1999-2018 Alibaba Group.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package util
+
+import (
+	"os"
+	"testing"
+)
+
+func writeMemFile(t *testing.T, fu *FileUtil, path string, content string) {
+	t.Helper()
+	f, err := fu.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatalf("OpenFile(%s) error = %v", path, err)
+	}
+	if _, err := f.Write([]byte(content)); err != nil {
+		t.Fatalf("Write(%s) error = %v", path, err)
+	}
+	f.Close()
+}
+
+func TestFileUtil_ComputeDigests(t *testing.T) {
+	fu := NewFileUtil(NewMemFs())
+	writeMemFile(t, fu, "/a", "hello world")
+
+	digests, err := fu.ComputeDigests("/a", AlgorithmMD5, AlgorithmSHA256)
+	if err != nil {
+		t.Fatalf("ComputeDigests() error = %v", err)
+	}
+	if len(digests) != 2 {
+		t.Fatalf("len(digests) = %d, want 2", len(digests))
+	}
+	if digests[0].Algorithm != AlgorithmMD5 || digests[0].Hex == "" {
+		t.Fatalf("digests[0] = %+v, want non-empty md5 digest", digests[0])
+	}
+	if digests[1].Algorithm != AlgorithmSHA256 || digests[1].Hex == "" {
+		t.Fatalf("digests[1] = %+v, want non-empty sha256 digest", digests[1])
+	}
+
+	single, err := fu.ComputeDigest("/a", AlgorithmSHA256)
+	if err != nil {
+		t.Fatalf("ComputeDigest() error = %v", err)
+	}
+	if single.Hex != digests[1].Hex {
+		t.Fatalf("ComputeDigest() = %s, want %s", single.Hex, digests[1].Hex)
+	}
+}
+
+func TestFileUtil_ComputeDigests_UnsupportedAlgorithm(t *testing.T) {
+	fu := NewFileUtil(NewMemFs())
+	writeMemFile(t, fu, "/a", "hello world")
+
+	if _, err := fu.ComputeDigest("/a", Algorithm("blake3")); err == nil {
+		t.Fatalf("ComputeDigest() error = nil, want error for unregistered algorithm")
+	}
+}
+
+func TestFileUtil_MoveFileAfterCheckDigest(t *testing.T) {
+	fu := NewFileUtil(NewMemFs())
+	writeMemFile(t, fu, "/src", "hello world")
+
+	digest, err := fu.ComputeDigest("/src", AlgorithmSHA256)
+	if err != nil {
+		t.Fatalf("ComputeDigest() error = %v", err)
+	}
+
+	if err := fu.MoveFileAfterCheckDigest("/src", "/dst", Digest{Algorithm: AlgorithmSHA256, Hex: "deadbeef"}); err == nil {
+		t.Fatalf("MoveFileAfterCheckDigest() error = nil, want mismatch error")
+	}
+	if err := fu.MoveFileAfterCheckDigest("/src", "/dst", digest); err != nil {
+		t.Fatalf("MoveFileAfterCheckDigest() error = %v", err)
+	}
+	if !fu.PathExist("/dst") {
+		t.Fatalf("PathExist(/dst) = false, want true after move")
+	}
+}
+
+func TestFileUtil_CopyFileWithDigests(t *testing.T) {
+	fu := NewFileUtil(NewMemFs())
+	writeMemFile(t, fu, "/src", "hello world")
+
+	digests, err := fu.CopyFileWithDigests("/src", "/dst", AlgorithmMD5)
+	if err != nil {
+		t.Fatalf("CopyFileWithDigests() error = %v", err)
+	}
+	want, err := fu.ComputeDigest("/dst", AlgorithmMD5)
+	if err != nil {
+		t.Fatalf("ComputeDigest() error = %v", err)
+	}
+	if len(digests) != 1 || digests[0].Hex != want.Hex {
+		t.Fatalf("CopyFileWithDigests() = %+v, want digest matching copied file %+v", digests, want)
+	}
+}
+
+func TestMd5Sum_IsShimOverComputeDigest(t *testing.T) {
+	fu := NewFileUtil(NewMemFs())
+	writeMemFile(t, fu, "/a", "hello world")
+
+	digest, err := fu.ComputeDigest("/a", AlgorithmMD5)
+	if err != nil {
+		t.Fatalf("ComputeDigest() error = %v", err)
+	}
+	if got := fu.Md5Sum("/a"); got != digest.Hex {
+		t.Fatalf("Md5Sum() = %s, want %s", got, digest.Hex)
+	}
+}
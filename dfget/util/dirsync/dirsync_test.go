@@ -0,0 +1,229 @@
+/*
+ * Copyright 1999-2018 Alibaba Group.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dirsync
+
+import (
+	"io"
+	"os"
+	"testing"
+
+	"github.com/dragonflyoss/Dragonfly/dfget/util"
+)
+
+func TestWalk_Deterministic(t *testing.T) {
+	fu := util.NewFileUtil(util.NewOsFs())
+	root, err := os.MkdirTemp("", "dragonfly-dirsync-walk-")
+	if err != nil {
+		t.Fatalf("MkdirTemp() error = %v", err)
+	}
+	defer os.RemoveAll(root)
+
+	if err := fu.CreateDirectory(root + "/sub"); err != nil {
+		t.Fatalf("CreateDirectory() error = %v", err)
+	}
+	writeFile(t, fu, root+"/b.txt", "b")
+	writeFile(t, fu, root+"/a.txt", "a")
+	writeFile(t, fu, root+"/sub/c.txt", "c")
+
+	m, err := Walk(fu, root, WalkOptions{})
+	if err != nil {
+		t.Fatalf("Walk() error = %v", err)
+	}
+	var rels []string
+	for _, e := range m.Entries {
+		rels = append(rels, e.RelPath)
+	}
+	want := []string{"a.txt", "b.txt", "sub", "sub/c.txt"}
+	if len(rels) != len(want) {
+		t.Fatalf("Walk() entries = %v, want %v", rels, want)
+	}
+	for i := range want {
+		if rels[i] != want[i] {
+			t.Fatalf("Walk() entries = %v, want %v", rels, want)
+		}
+	}
+}
+
+func TestDiffApplyPlan_RoundTrip(t *testing.T) {
+	fu := util.NewFileUtil(util.NewOsFs())
+
+	remoteDir, err := os.MkdirTemp("", "dragonfly-dirsync-remote-")
+	if err != nil {
+		t.Fatalf("MkdirTemp() error = %v", err)
+	}
+	defer os.RemoveAll(remoteDir)
+	writeFile(t, fu, remoteDir+"/keep.txt", "keep")
+	writeFile(t, fu, remoteDir+"/changed.txt", "new content")
+	writeFile(t, fu, remoteDir+"/added.txt", "added")
+
+	localDir, err := os.MkdirTemp("", "dragonfly-dirsync-local-")
+	if err != nil {
+		t.Fatalf("MkdirTemp() error = %v", err)
+	}
+	defer os.RemoveAll(localDir)
+	writeFile(t, fu, localDir+"/keep.txt", "keep")
+	writeFile(t, fu, localDir+"/changed.txt", "old content")
+	writeFile(t, fu, localDir+"/removed.txt", "stale")
+
+	remote, err := Walk(fu, remoteDir, WalkOptions{})
+	if err != nil {
+		t.Fatalf("Walk(remote) error = %v", err)
+	}
+	local, err := Walk(fu, localDir, WalkOptions{})
+	if err != nil {
+		t.Fatalf("Walk(local) error = %v", err)
+	}
+
+	plan := Diff(local, remote)
+	if len(plan.Add) != 1 || plan.Add[0].RelPath != "added.txt" {
+		t.Fatalf("plan.Add = %v, want [added.txt]", plan.Add)
+	}
+	if len(plan.Update) != 1 || plan.Update[0].RelPath != "changed.txt" {
+		t.Fatalf("plan.Update = %v, want [changed.txt]", plan.Update)
+	}
+	if len(plan.Delete) != 1 || plan.Delete[0] != "removed.txt" {
+		t.Fatalf("plan.Delete = %v, want [removed.txt]", plan.Delete)
+	}
+
+	fetch := func(relPath string) (io.ReadCloser, error) {
+		return fu.Fs.Open(remoteDir + "/" + relPath)
+	}
+	if err := ApplyPlan(fu, localDir, plan, fetch); err != nil {
+		t.Fatalf("ApplyPlan() error = %v", err)
+	}
+
+	synced, err := Walk(fu, localDir, WalkOptions{})
+	if err != nil {
+		t.Fatalf("Walk(synced) error = %v", err)
+	}
+	if diff := Diff(synced, remote); len(diff.Add) != 0 || len(diff.Update) != 0 || len(diff.Delete) != 0 {
+		t.Fatalf("Diff(synced, remote) = %+v, want an empty plan", diff)
+	}
+}
+
+func TestDiffApplyPlan_Symlink(t *testing.T) {
+	fu := util.NewFileUtil(util.NewOsFs())
+
+	remoteDir, err := os.MkdirTemp("", "dragonfly-dirsync-symlink-remote-")
+	if err != nil {
+		t.Fatalf("MkdirTemp() error = %v", err)
+	}
+	defer os.RemoveAll(remoteDir)
+	writeFile(t, fu, remoteDir+"/real.txt", "hello")
+	if err := os.Symlink("real.txt", remoteDir+"/link.txt"); err != nil {
+		t.Fatalf("Symlink() error = %v", err)
+	}
+
+	localDir, err := os.MkdirTemp("", "dragonfly-dirsync-symlink-local-")
+	if err != nil {
+		t.Fatalf("MkdirTemp() error = %v", err)
+	}
+	defer os.RemoveAll(localDir)
+
+	remote, err := Walk(fu, remoteDir, WalkOptions{})
+	if err != nil {
+		t.Fatalf("Walk(remote) error = %v", err)
+	}
+	local, err := Walk(fu, localDir, WalkOptions{})
+	if err != nil {
+		t.Fatalf("Walk(local) error = %v", err)
+	}
+
+	plan := Diff(local, remote)
+	fetch := func(relPath string) (io.ReadCloser, error) {
+		return fu.Fs.Open(remoteDir + "/" + relPath)
+	}
+	if err := ApplyPlan(fu, localDir, plan, fetch); err != nil {
+		t.Fatalf("ApplyPlan() error = %v", err)
+	}
+
+	target, err := os.Readlink(localDir + "/link.txt")
+	if err != nil {
+		t.Fatalf("Readlink() error = %v", err)
+	}
+	if target != "real.txt" {
+		t.Fatalf("Readlink() = %q, want %q", target, "real.txt")
+	}
+}
+
+func TestDiffApplyPlan_ReplacesDanglingSymlink(t *testing.T) {
+	fu := util.NewFileUtil(util.NewOsFs())
+
+	remoteDir, err := os.MkdirTemp("", "dragonfly-dirsync-dangling-remote-")
+	if err != nil {
+		t.Fatalf("MkdirTemp() error = %v", err)
+	}
+	defer os.RemoveAll(remoteDir)
+	writeFile(t, fu, remoteDir+"/real.txt", "hello")
+	if err := os.Symlink("real.txt", remoteDir+"/link.txt"); err != nil {
+		t.Fatalf("Symlink() error = %v", err)
+	}
+
+	localDir, err := os.MkdirTemp("", "dragonfly-dirsync-dangling-local-")
+	if err != nil {
+		t.Fatalf("MkdirTemp() error = %v", err)
+	}
+	defer os.RemoveAll(localDir)
+	// Pre-seed dst as a symlink whose target doesn't exist, e.g. left
+	// behind by a previous sync whose target since got cleaned up --
+	// fu.PathExist(dst) can't see past this to know an entry is there at
+	// all, since Stat follows the dangling link and fails.
+	if err := os.Symlink("stale-target.txt", localDir+"/link.txt"); err != nil {
+		t.Fatalf("Symlink() error = %v", err)
+	}
+
+	remote, err := Walk(fu, remoteDir, WalkOptions{})
+	if err != nil {
+		t.Fatalf("Walk(remote) error = %v", err)
+	}
+	local, err := Walk(fu, localDir, WalkOptions{})
+	if err != nil {
+		t.Fatalf("Walk(local) error = %v", err)
+	}
+
+	plan := Diff(local, remote)
+	if len(plan.Update) != 1 || plan.Update[0].RelPath != "link.txt" {
+		t.Fatalf("plan.Update = %v, want [link.txt]", plan.Update)
+	}
+
+	fetch := func(relPath string) (io.ReadCloser, error) {
+		return fu.Fs.Open(remoteDir + "/" + relPath)
+	}
+	if err := ApplyPlan(fu, localDir, plan, fetch); err != nil {
+		t.Fatalf("ApplyPlan() error = %v", err)
+	}
+
+	target, err := os.Readlink(localDir + "/link.txt")
+	if err != nil {
+		t.Fatalf("Readlink() error = %v", err)
+	}
+	if target != "real.txt" {
+		t.Fatalf("Readlink() = %q, want %q", target, "real.txt")
+	}
+}
+
+func writeFile(t *testing.T, fu *util.FileUtil, path string, content string) {
+	t.Helper()
+	f, err := fu.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatalf("OpenFile(%s) error = %v", path, err)
+	}
+	if _, err := f.Write([]byte(content)); err != nil {
+		t.Fatalf("Write(%s) error = %v", path, err)
+	}
+	f.Close()
+}
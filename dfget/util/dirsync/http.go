@@ -0,0 +1,64 @@
+/*
+ * Copyright 1999-2018 Alibaba Group.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dirsync
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/dragonflyoss/Dragonfly/dfget/util"
+)
+
+// ServeManifest walks root and writes its Manifest to w as a gzip-streamed
+// response, so a supernode or edge node can be pointed at this handler by
+// a peer that wants to warm its cache from it.
+func ServeManifest(w http.ResponseWriter, fu *util.FileUtil, root string, opts WalkOptions) error {
+	m, err := Walk(fu, root, opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+	r, err := m.Reader()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+	// Content-Type is deliberately application/gzip rather than
+	// application/x-ndjson plus a Content-Encoding: gzip header: the
+	// latter makes net/http's transport transparently decompress the
+	// body and strip the header, leaving FetchManifest's gzip.NewReader
+	// looking at already-decompressed JSON.
+	w.Header().Set("Content-Type", "application/gzip")
+	_, err = io.Copy(w, r)
+	return err
+}
+
+// FetchManifest fetches and decodes the Manifest served by ServeManifest
+// at url.
+func FetchManifest(url string) (Manifest, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("fetch manifest:%s error, %v", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Manifest{}, fmt.Errorf("fetch manifest:%s error, unexpected status %s", url, resp.Status)
+	}
+	return DecodeManifest(resp.Body)
+}
@@ -0,0 +1,51 @@
+/*
+ * Copyright 1999-2018 Alibaba Group.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dirsync
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/dragonflyoss/Dragonfly/dfget/util"
+)
+
+func TestServeFetchManifest_RoundTrip(t *testing.T) {
+	fu := util.NewFileUtil(util.NewOsFs())
+	root, err := os.MkdirTemp("", "dragonfly-dirsync-http-")
+	if err != nil {
+		t.Fatalf("MkdirTemp() error = %v", err)
+	}
+	defer os.RemoveAll(root)
+	writeFile(t, fu, root+"/a.txt", "hello")
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := ServeManifest(w, fu, root, WalkOptions{}); err != nil {
+			t.Errorf("ServeManifest() error = %v", err)
+		}
+	}))
+	defer srv.Close()
+
+	m, err := FetchManifest(srv.URL)
+	if err != nil {
+		t.Fatalf("FetchManifest() error = %v", err)
+	}
+	if len(m.Entries) != 1 || m.Entries[0].RelPath != "a.txt" {
+		t.Fatalf("FetchManifest() entries = %v, want [a.txt]", m.Entries)
+	}
+}
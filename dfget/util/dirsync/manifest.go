@@ -0,0 +1,193 @@
+/*
+ * Copyright 1999-2018 Alibaba Group.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package dirsync builds on util's Filesystem and digest helpers to diff
+// and sync whole directory trees, e.g. warming a supernode's cache from a
+// peer instead of re-downloading every piece, or preheating an edge node
+// ahead of a scheduled hot task.
+package dirsync
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/dragonflyoss/Dragonfly/dfget/util"
+)
+
+// Entry describes a single file, directory or symlink within a Manifest.
+type Entry struct {
+	// RelPath is the entry's path relative to the walked root, always
+	// slash-separated regardless of platform.
+	RelPath string      `json:"relpath"`
+	Size    int64       `json:"size"`
+	Mode    os.FileMode `json:"mode"`
+	ModTime time.Time   `json:"mtime"`
+	// Digest is the content digest of a regular file. It is zero for
+	// directories and for symlinks that weren't followed.
+	Digest util.Digest `json:"digest,omitempty"`
+	// LinkTarget is set instead of Digest when the entry is a symlink
+	// that WalkOptions.FollowSymlinks left unresolved.
+	LinkTarget string `json:"link_target,omitempty"`
+}
+
+// IsDir reports whether the entry is a directory.
+func (e Entry) IsDir() bool {
+	return e.Mode.IsDir()
+}
+
+// IsSymlink reports whether the entry is an unresolved symlink, i.e. one
+// recorded with LinkTarget rather than a Digest.
+func (e Entry) IsSymlink() bool {
+	return e.Mode&os.ModeSymlink != 0 && e.Digest.Hex == ""
+}
+
+// Manifest enumerates the entries of a directory tree in deterministic,
+// lexicographic-by-RelPath order.
+type Manifest struct {
+	Entries []Entry
+}
+
+// WalkOptions configures Walk.
+type WalkOptions struct {
+	// Algorithm is used to digest regular files. Defaults to
+	// util.AlgorithmSHA256 when empty.
+	Algorithm util.Algorithm
+	// FollowSymlinks controls how symlinks are recorded. When false (the
+	// default), a symlink is recorded as an Entry with LinkTarget set and
+	// no Digest. When true, the symlink is resolved and digested like a
+	// regular file.
+	FollowSymlinks bool
+}
+
+// Walk builds a Manifest for the directory tree rooted at root, using fu's
+// Filesystem so it works the same way over a real disk or a util.MemFs.
+// The root directory itself is not included as an entry.
+func Walk(fu *util.FileUtil, root string, opts WalkOptions) (Manifest, error) {
+	algo := opts.Algorithm
+	if algo == "" {
+		algo = util.AlgorithmSHA256
+	}
+
+	var m Manifest
+	if err := walkDir(fu, root, root, opts, algo, &m); err != nil {
+		return Manifest{}, err
+	}
+	sort.Slice(m.Entries, func(i, j int) bool {
+		return m.Entries[i].RelPath < m.Entries[j].RelPath
+	})
+	return m, nil
+}
+
+func walkDir(fu *util.FileUtil, root string, dir string, opts WalkOptions, algo util.Algorithm, m *Manifest) error {
+	infos, err := fu.Fs.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("walk dir:%s error, %v", dir, err)
+	}
+	for _, info := range infos {
+		path := filepath.Join(dir, info.Name())
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		entry, err := buildEntry(fu, path, rel, info, opts, algo)
+		if err != nil {
+			return err
+		}
+		m.Entries = append(m.Entries, entry)
+
+		if info.IsDir() {
+			if err := walkDir(fu, root, path, opts, algo, m); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func buildEntry(fu *util.FileUtil, path string, rel string, info os.FileInfo, opts WalkOptions, algo util.Algorithm) (Entry, error) {
+	entry := Entry{
+		RelPath: rel,
+		Size:    info.Size(),
+		Mode:    info.Mode(),
+		ModTime: info.ModTime(),
+	}
+
+	if info.Mode()&os.ModeSymlink != 0 && !opts.FollowSymlinks {
+		target, err := fu.Fs.Readlink(path)
+		if err != nil {
+			return Entry{}, fmt.Errorf("walk dir:%s error, %v", path, err)
+		}
+		entry.LinkTarget = target
+		return entry, nil
+	}
+
+	if !info.Mode().IsRegular() && info.Mode()&os.ModeSymlink == 0 {
+		return entry, nil
+	}
+
+	digest, err := fu.ComputeDigest(path, algo)
+	if err != nil {
+		return Entry{}, fmt.Errorf("walk dir:%s error, %v", path, err)
+	}
+	entry.Digest = digest
+	return entry, nil
+}
+
+// Reader serializes the manifest as gzip-compressed, newline-delimited
+// JSON, one Entry per line, suitable for streaming over HTTP.
+func (m Manifest) Reader() (io.Reader, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(gz)
+	for _, e := range m.Entries {
+		if err := enc.Encode(e); err != nil {
+			return nil, fmt.Errorf("encode manifest entry:%s error, %v", e.RelPath, err)
+		}
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return &buf, nil
+}
+
+// DecodeManifest reads a Manifest serialized by Manifest.Reader.
+func DecodeManifest(r io.Reader) (Manifest, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("decode manifest error, %v", err)
+	}
+	defer gz.Close()
+
+	var m Manifest
+	dec := json.NewDecoder(gz)
+	for dec.More() {
+		var e Entry
+		if err := dec.Decode(&e); err != nil {
+			return Manifest{}, fmt.Errorf("decode manifest entry error, %v", err)
+		}
+		m.Entries = append(m.Entries, e)
+	}
+	return m, nil
+}
@@ -0,0 +1,181 @@
+/*
+ * Copyright 1999-2018 Alibaba Group.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dirsync
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/dragonflyoss/Dragonfly/dfget/util"
+)
+
+// DiffPlan is the result of diffing a local Manifest against a remote one:
+// the set of changes that would bring the local tree in line with the
+// remote one. Directories aren't listed explicitly -- they're created as
+// needed by the files under them.
+type DiffPlan struct {
+	Add    []Entry
+	Update []Entry
+	// Delete holds the RelPath of every local entry absent from the
+	// remote manifest.
+	Delete []string
+}
+
+// Diff compares local against remote and returns the files to add,
+// update and delete to bring local in line with remote. Entry order
+// follows each Manifest's, which Walk already produces in lexicographic
+// order.
+func Diff(local, remote Manifest) DiffPlan {
+	localByPath := indexEntries(local)
+	remoteByPath := indexEntries(remote)
+
+	var plan DiffPlan
+	for _, re := range remote.Entries {
+		if re.IsDir() {
+			continue
+		}
+		le, ok := localByPath[re.RelPath]
+		switch {
+		case !ok:
+			plan.Add = append(plan.Add, re)
+		case !entriesMatch(le, re):
+			plan.Update = append(plan.Update, re)
+		}
+	}
+	for _, le := range local.Entries {
+		if le.IsDir() {
+			continue
+		}
+		if _, ok := remoteByPath[le.RelPath]; !ok {
+			plan.Delete = append(plan.Delete, le.RelPath)
+		}
+	}
+	return plan
+}
+
+func indexEntries(m Manifest) map[string]Entry {
+	idx := make(map[string]Entry, len(m.Entries))
+	for _, e := range m.Entries {
+		idx[e.RelPath] = e
+	}
+	return idx
+}
+
+func entriesMatch(a, b Entry) bool {
+	if a.IsSymlink() || b.IsSymlink() {
+		return a.IsSymlink() == b.IsSymlink() && a.LinkTarget == b.LinkTarget
+	}
+	return a.Digest.Algorithm == b.Digest.Algorithm && a.Digest.Hex == b.Digest.Hex
+}
+
+// Fetcher retrieves the content of the file at relPath from whatever
+// transport the caller diffed the remote Manifest over, e.g. an HTTP GET
+// against the peer ServeManifest is running on.
+type Fetcher func(relPath string) (io.ReadCloser, error)
+
+// ApplyPlan brings root in line with the remote Manifest plan was diffed
+// against: every Add/Update entry is fetched and written into place,
+// every Delete entry is removed.
+//
+// Downloads are resumable: content is staged in a sibling
+// `<dst>.partial.<random>` file, verified against the entry's Digest, and
+// only then moved into place with FileUtil.MoveFileAfterCheckDigest,
+// which removes any existing dst and then renames the partial file via
+// Filesystem.Rename. A download interrupted
+// partway through leaves the original dst (if any) untouched and only an
+// orphaned partial file behind.
+func ApplyPlan(fu *util.FileUtil, root string, plan DiffPlan, fetch Fetcher) error {
+	for _, e := range plan.Add {
+		if err := applyEntry(fu, root, e, fetch); err != nil {
+			return err
+		}
+	}
+	for _, e := range plan.Update {
+		if err := applyEntry(fu, root, e, fetch); err != nil {
+			return err
+		}
+	}
+	for _, rel := range plan.Delete {
+		// Fs.Remove rather than DeleteFile: DeleteFile Stats through a
+		// symlink, so it fails on a dangling one.
+		if err := fu.Fs.Remove(filepath.Join(root, filepath.FromSlash(rel))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyEntry(fu *util.FileUtil, root string, e Entry, fetch Fetcher) error {
+	dst := filepath.Join(root, filepath.FromSlash(e.RelPath))
+
+	if e.IsSymlink() {
+		// fu.PathExist(dst) would Stat through a dangling symlink and
+		// report it as absent, leaving the stale entry in place for the
+		// Symlink call below to fail against with "file exists". Remove
+		// unconditionally instead -- it unlinks dst itself rather than
+		// whatever it points to, so a dangling symlink is removed just
+		// as well as a live one -- and ignore the no-such-entry case.
+		if err := fu.Fs.Remove(dst); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if err := fu.CreateDirectory(filepath.Dir(dst)); err != nil {
+			return err
+		}
+		return fu.Fs.Symlink(e.LinkTarget, dst)
+	}
+
+	r, err := fetch(e.RelPath)
+	if err != nil {
+		return fmt.Errorf("apply entry:%s error, %v", e.RelPath, err)
+	}
+	defer r.Close()
+
+	partial := dst + ".partial." + randomSuffix()
+	w, err := fu.OpenFile(partial, os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		fu.DeleteFiles(partial)
+		return fmt.Errorf("apply entry:%s error, %v", e.RelPath, err)
+	}
+	if err := w.Close(); err != nil {
+		fu.DeleteFiles(partial)
+		return err
+	}
+
+	if err := fu.MoveFileAfterCheckDigest(partial, dst, e.Digest); err != nil {
+		fu.DeleteFiles(partial)
+		return fmt.Errorf("apply entry:%s error, %v", e.RelPath, err)
+	}
+	return fu.Fs.Chtimes(dst, e.ModTime, e.ModTime)
+}
+
+// randomSuffix returns a short random hex string used to make concurrent
+// partial downloads of the same entry collision-free.
+func randomSuffix() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "0"
+	}
+	return hex.EncodeToString(b)
+}
@@ -17,22 +17,22 @@
 package util
 
 import (
-	"bufio"
-	"crypto/md5"
+	"context"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"sync"
 )
 
 // BufferSize define the buffer size when reading and writing file
 const BufferSize = 8 * 1024 * 1024
 
 // CreateDirectory creates directory recursively.
-func CreateDirectory(dirPath string) error {
-	f, e := os.Stat(dirPath)
+func (fu *FileUtil) CreateDirectory(dirPath string) error {
+	f, e := fu.Fs.Stat(dirPath)
 	if e != nil && os.IsNotExist(e) {
-		return os.MkdirAll(dirPath, 0755)
+		return fu.Fs.MkdirAll(dirPath, 0755)
 	}
 	if e == nil && !f.IsDir() {
 		return fmt.Errorf("create dir:%s error, not a directory", dirPath)
@@ -41,21 +41,21 @@ func CreateDirectory(dirPath string) error {
 }
 
 // DeleteFile deletes a file not a directory.
-func DeleteFile(filePath string) error {
-	if !PathExist(filePath) {
+func (fu *FileUtil) DeleteFile(filePath string) error {
+	if !fu.PathExist(filePath) {
 		return fmt.Errorf("delete file:%s error, file not exist", filePath)
 	}
-	if IsDir(filePath) {
+	if fu.IsDir(filePath) {
 		return fmt.Errorf("delete file:%s error, is a directory instead of a file", filePath)
 	}
-	return os.Remove(filePath)
+	return fu.Fs.Remove(filePath)
 }
 
 // DeleteFiles deletes all the given files.
-func DeleteFiles(filePaths ...string) {
+func (fu *FileUtil) DeleteFiles(filePaths ...string) {
 	if len(filePaths) > 0 {
 		for _, f := range filePaths {
-			if err := DeleteFile(f); err != nil {
+			if err := fu.DeleteFile(f); err != nil {
 				continue
 			}
 		}
@@ -65,104 +65,212 @@ func DeleteFiles(filePaths ...string) {
 
 // OpenFile open a file. If the file isn't exist, it will create the file.
 // If the directory isn't exist, it will create the directory.
-func OpenFile(path string, flag int, perm os.FileMode) (*os.File, error) {
-	if PathExist(path) {
-		return os.OpenFile(path, flag, perm)
+func (fu *FileUtil) OpenFile(path string, flag int, perm os.FileMode) (File, error) {
+	if fu.PathExist(path) {
+		return fu.Fs.OpenFile(path, flag, perm)
 	}
 	pathDir := filepath.Dir(path)
 	// when path is only a file name, e.g: a.txt, the pathDir is current path ".", then just create it
 	if pathDir == "." {
-		return os.OpenFile(path, flag, perm)
+		return fu.Fs.OpenFile(path, flag, perm)
 	}
-	if err := CreateDirectory(pathDir); err != nil {
+	if err := fu.CreateDirectory(pathDir); err != nil {
 		return nil, err
 	}
-	return os.OpenFile(path, flag, perm)
+	return fu.Fs.OpenFile(path, flag, perm)
 }
 
 // Link creates a hard link pointing to src named linkName.
-func Link(src string, linkName string) error {
-	if PathExist(linkName) {
-		if err := DeleteFile(linkName); err != nil {
+func (fu *FileUtil) Link(src string, linkName string) error {
+	if fu.PathExist(linkName) {
+		if err := fu.DeleteFile(linkName); err != nil {
 			return err
 		}
 	}
-	return os.Link(src, linkName)
+	return fu.Fs.Link(src, linkName)
 }
 
-// CopyFile copies the file src to dst.
-func CopyFile(src string, dst string) error {
-	if !IsRegularFile(src) {
-		return fmt.Errorf("copy file:%s error, is not a regular file", src)
+// bufferPool holds reusable BufferSize-sized buffers for CopyFile and its
+// variants, so a node copying many pieces concurrently doesn't pin a fresh
+// 8 MiB of RSS per copy.
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return make([]byte, BufferSize)
+	},
+}
+
+// CopyFile copies the file src to dst. When the destination supports
+// io.ReaderFrom (as *os.File does; on Linux its ReadFrom can use the
+// copy_file_range/sendfile fast path), the copy is handed to ReadFrom;
+// otherwise it falls back to a pooled buffer.
+func (fu *FileUtil) CopyFile(src string, dst string) error {
+	_, err := fu.copyFile(context.Background(), src, dst, nil)
+	return err
+}
+
+// CopyFileContext is CopyFile with a context: the copy is done in
+// BufferSize chunks and aborts as soon as ctx is done, which matters when
+// a task GC needs to cut short a copy of an in-flight piece.
+func (fu *FileUtil) CopyFileContext(ctx context.Context, src string, dst string) error {
+	_, err := fu.copyFile(ctx, src, dst, nil)
+	return err
+}
+
+// CopyFileWithDigests copies the file src to dst like CopyFile, additionally
+// computing a Digest per algo in the same pass, so a piece download can
+// verify-while-writing instead of re-reading the file afterwards.
+func (fu *FileUtil) CopyFileWithDigests(src string, dst string, algos ...Algorithm) ([]Digest, error) {
+	hashers := make([]Hasher, len(algos))
+	for i, algo := range algos {
+		h, err := newHasher(algo)
+		if err != nil {
+			return nil, err
+		}
+		hashers[i] = h
 	}
-	s, err := OpenFile(src, os.O_RDONLY, 0666)
+	if _, err := fu.copyFile(context.Background(), src, dst, hashers); err != nil {
+		return nil, err
+	}
+	digests := make([]Digest, len(algos))
+	for i, algo := range algos {
+		digests[i] = Digest{Algorithm: algo, Hex: fmt.Sprintf("%x", hashers[i].Sum(nil))}
+	}
+	return digests, nil
+}
+
+// AppendFile appends the content of src to the end of dst, creating dst if
+// it doesn't already exist.
+func (fu *FileUtil) AppendFile(src string, dst string) (int64, error) {
+	if !fu.IsRegularFile(src) {
+		return 0, fmt.Errorf("append file:%s error, is not a regular file", src)
+	}
+	s, err := fu.OpenFile(src, os.O_RDONLY, 0666)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer s.Close()
 
-	if PathExist(dst) {
-		return fmt.Errorf("copy file:%s error, dst file already exists", dst)
+	d, err := fu.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0755)
+	if err != nil {
+		return 0, err
 	}
+	defer d.Close()
 
-	d, err := OpenFile(dst, os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0755)
+	return fu.copyBuffered(s, d, nil)
+}
+
+// copyFile is the shared implementation backing CopyFile, CopyFileContext
+// and CopyFileWithDigests: it opens src/dst and copies one to the other,
+// tee-ing every byte written through hashers if given.
+func (fu *FileUtil) copyFile(ctx context.Context, src string, dst string, hashers []Hasher) (int64, error) {
+	if !fu.IsRegularFile(src) {
+		return 0, fmt.Errorf("copy file:%s error, is not a regular file", src)
+	}
+	s, err := fu.OpenFile(src, os.O_RDONLY, 0666)
 	if err != nil {
-		return err
+		return 0, err
+	}
+	defer s.Close()
+
+	if fu.PathExist(dst) {
+		return 0, fmt.Errorf("copy file:%s error, dst file already exists", dst)
+	}
+
+	d, err := fu.OpenFile(dst, os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0755)
+	if err != nil {
+		return 0, err
 	}
 	defer d.Close()
 
-	buf := make([]byte, BufferSize)
+	// The zero-copy fast path only applies when the whole file is copied
+	// unconditionally: no hashing to tee through and no cancellation to
+	// observe mid-copy.
+	if len(hashers) == 0 && ctx.Done() == nil {
+		if rf, ok := d.(io.ReaderFrom); ok {
+			return rf.ReadFrom(s)
+		}
+	}
+
+	return fu.copyBufferedContext(ctx, s, d, hashers)
+}
+
+// copyBuffered copies all of src to dst using a pooled buffer, tee-ing
+// every chunk through hashers if given.
+func (fu *FileUtil) copyBuffered(src io.Reader, dst io.Writer, hashers []Hasher) (int64, error) {
+	return fu.copyBufferedContext(context.Background(), src, dst, hashers)
+}
+
+// copyBufferedContext is copyBuffered with cancellation: it checks ctx
+// between chunks so a caller can abort a long copy mid-flight.
+func (fu *FileUtil) copyBufferedContext(ctx context.Context, src io.Reader, dst io.Writer, hashers []Hasher) (int64, error) {
+	buf := bufferPool.Get().([]byte)
+	defer bufferPool.Put(buf)
+
+	var written int64
 	for {
-		n, err := s.Read(buf)
-		if err != nil && err != io.EOF {
-			return err
+		if ctx.Done() != nil {
+			select {
+			case <-ctx.Done():
+				return written, ctx.Err()
+			default:
+			}
+		}
+		n, err := src.Read(buf)
+		if n > 0 {
+			if _, werr := dst.Write(buf[:n]); werr != nil {
+				return written, werr
+			}
+			for _, h := range hashers {
+				h.Write(buf[:n])
+			}
+			written += int64(n)
 		}
-		if n == 0 || err == io.EOF {
+		if err == io.EOF {
 			break
 		}
-		if _, err := d.Write(buf[:n]); err != nil {
-			return err
+		if err != nil {
+			return written, err
+		}
+		if n == 0 {
+			break
 		}
 	}
-	return nil
+	return written, nil
 }
 
 // MoveFile moves the file src to dst.
-func MoveFile(src string, dst string) error {
-	if !IsRegularFile(src) {
+func (fu *FileUtil) MoveFile(src string, dst string) error {
+	if !fu.IsRegularFile(src) {
 		return fmt.Errorf("move file:%s error, is not a regular file", src)
 	}
-	if PathExist(dst) && !IsDir(dst) {
-		if err := DeleteFile(dst); err != nil {
+	if fu.PathExist(dst) && !fu.IsDir(dst) {
+		if err := fu.DeleteFile(dst); err != nil {
 			return err
 		}
 	}
-	return os.Rename(src, dst)
+	return fu.Fs.Rename(src, dst)
 }
 
 // MoveFileAfterCheckMd5 will check whether the file's md5 is equals to the param md5
 // before move the file src to dst.
-func MoveFileAfterCheckMd5(src string, dst string, md5 string) error {
-	if !IsRegularFile(src) {
-		return fmt.Errorf("move file with md5 check:%s error, is not a regular file", src)
-	}
-	m := Md5Sum(src)
-	if m != md5 {
-		return fmt.Errorf("move file with md5 check:%s error, md5 of srouce file doesn't match against the given md5 value", src)
-	}
-	return MoveFile(src, dst)
+//
+// Deprecated: this is a thin shim over MoveFileAfterCheckDigest kept for
+// backward compatibility; new callers should use MoveFileAfterCheckDigest
+// with AlgorithmMD5 or a stronger algorithm directly.
+func (fu *FileUtil) MoveFileAfterCheckMd5(src string, dst string, md5 string) error {
+	return fu.MoveFileAfterCheckDigest(src, dst, Digest{Algorithm: AlgorithmMD5, Hex: md5})
 }
 
 // PathExist reports whether the path is exist.
-// Any error get from os.Stat, it will return false.
-func PathExist(name string) bool {
-	_, err := os.Stat(name)
+// Any error get from Fs.Stat, it will return false.
+func (fu *FileUtil) PathExist(name string) bool {
+	_, err := fu.Fs.Stat(name)
 	return err == nil
 }
 
 // IsDir reports whether the path is a directory.
-func IsDir(name string) bool {
-	f, e := os.Stat(name)
+func (fu *FileUtil) IsDir(name string) bool {
+	f, e := fu.Fs.Stat(name)
 	if e != nil {
 		return false
 	}
@@ -170,8 +278,8 @@ func IsDir(name string) bool {
 }
 
 // IsRegularFile reports whether the file is a regular file
-func IsRegularFile(name string) bool {
-	f, e := os.Stat(name)
+func (fu *FileUtil) IsRegularFile(name string) bool {
+	f, e := fu.Fs.Stat(name)
 	if e != nil {
 		return false
 	}
@@ -179,23 +287,103 @@ func IsRegularFile(name string) bool {
 }
 
 // Md5Sum generate md5 for a given file
-func Md5Sum(name string) string {
-	if !IsRegularFile(name) {
-		return ""
-	}
-	f, err := OpenFile(name, os.O_RDONLY, 0666)
+//
+// Deprecated: this is a thin shim over ComputeDigest kept for backward
+// compatibility; new callers should use ComputeDigest/ComputeDigests,
+// which also support sha256, sha512 and crc32c.
+func (fu *FileUtil) Md5Sum(name string) string {
+	d, err := fu.ComputeDigest(name, AlgorithmMD5)
 	if err != nil {
 		return ""
 	}
-	defer f.Close()
-	r := bufio.NewReaderSize(f, BufferSize)
-	h := md5.New()
+	return d.Hex
+}
+
+// CreateDirectory creates directory recursively.
+func CreateDirectory(dirPath string) error {
+	return defaultFileUtil.CreateDirectory(dirPath)
+}
 
-	_, err = io.Copy(h, r)
+// DeleteFile deletes a file not a directory.
+func DeleteFile(filePath string) error {
+	return defaultFileUtil.DeleteFile(filePath)
+}
+
+// DeleteFiles deletes all the given files.
+func DeleteFiles(filePaths ...string) {
+	defaultFileUtil.DeleteFiles(filePaths...)
+}
+
+// OpenFile open a file. If the file isn't exist, it will create the file.
+// If the directory isn't exist, it will create the directory.
+func OpenFile(path string, flag int, perm os.FileMode) (*os.File, error) {
+	f, err := defaultFileUtil.OpenFile(path, flag, perm)
 	if err != nil {
-		return ""
+		return nil, err
+	}
+	osFile, ok := f.(*os.File)
+	if !ok {
+		return nil, fmt.Errorf("open file:%s error, default Fs did not return an *os.File", path)
 	}
+	return osFile, nil
+}
+
+// Link creates a hard link pointing to src named linkName.
+func Link(src string, linkName string) error {
+	return defaultFileUtil.Link(src, linkName)
+}
 
-	return fmt.Sprintf("%x", h.Sum(nil))
+// CopyFile copies the file src to dst.
+func CopyFile(src string, dst string) error {
+	return defaultFileUtil.CopyFile(src, dst)
+}
+
+// CopyFileWithDigests copies the file src to dst, additionally computing a
+// Digest per algo in the same pass.
+func CopyFileWithDigests(src string, dst string, algos ...Algorithm) ([]Digest, error) {
+	return defaultFileUtil.CopyFileWithDigests(src, dst, algos...)
+}
 
-}
\ No newline at end of file
+// CopyFileContext is CopyFile with a context: the copy aborts as soon as
+// ctx is done.
+func CopyFileContext(ctx context.Context, src string, dst string) error {
+	return defaultFileUtil.CopyFileContext(ctx, src, dst)
+}
+
+// AppendFile appends the content of src to the end of dst, creating dst if
+// it doesn't already exist.
+func AppendFile(src string, dst string) (int64, error) {
+	return defaultFileUtil.AppendFile(src, dst)
+}
+
+// MoveFile moves the file src to dst.
+func MoveFile(src string, dst string) error {
+	return defaultFileUtil.MoveFile(src, dst)
+}
+
+// MoveFileAfterCheckMd5 will check whether the file's md5 is equals to the param md5
+// before move the file src to dst.
+func MoveFileAfterCheckMd5(src string, dst string, md5 string) error {
+	return defaultFileUtil.MoveFileAfterCheckMd5(src, dst, md5)
+}
+
+// PathExist reports whether the path is exist.
+// Any error get from Fs.Stat, it will return false.
+func PathExist(name string) bool {
+	return defaultFileUtil.PathExist(name)
+}
+
+// IsDir reports whether the path is a directory.
+func IsDir(name string) bool {
+	return defaultFileUtil.IsDir(name)
+}
+
+// IsRegularFile reports whether the file is a regular file
+func IsRegularFile(name string) bool {
+	return defaultFileUtil.IsRegularFile(name)
+}
+
+// Md5Sum generate md5 for a given file
+func Md5Sum(name string) string {
+	return defaultFileUtil.Md5Sum(name)
+}
@@ -0,0 +1,191 @@
+/*
+ * Copyright 1999-2018 Alibaba Group.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package util
+
+import (
+	"io"
+	"os"
+	"time"
+)
+
+// File is the minimal set of *os.File behaviors that Filesystem
+// implementations must provide. *os.File already satisfies it.
+type File interface {
+	io.Reader
+	io.Writer
+	io.Closer
+	io.Seeker
+
+	Name() string
+	Stat() (os.FileInfo, error)
+}
+
+// Filesystem abstracts the subset of the os package that the file helpers
+// in this package depend on, so that callers can inject an in-memory or
+// chrooted implementation instead of always touching the local disk.
+//
+// It is intentionally small and afero-like rather than a full mirror of
+// the os package: only the operations this package actually needs.
+type Filesystem interface {
+	Stat(name string) (os.FileInfo, error)
+	Open(name string) (File, error)
+	OpenFile(name string, flag int, perm os.FileMode) (File, error)
+	Mkdir(name string, perm os.FileMode) error
+	MkdirAll(name string, perm os.FileMode) error
+	Rename(oldpath, newpath string) error
+	Remove(name string) error
+	Link(oldname, newname string) error
+	Symlink(oldname, newname string) error
+	Readlink(name string) (string, error)
+	Chtimes(name string, atime, mtime time.Time) error
+	ReadDir(name string) ([]os.FileInfo, error)
+}
+
+// OsFs is a Filesystem backed by the real local disk via the os package.
+// It is the default Fs used by the package-level helper functions.
+type OsFs struct{}
+
+// NewOsFs creates an OsFs.
+func NewOsFs() *OsFs {
+	return &OsFs{}
+}
+
+func (OsFs) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(preparePath(name))
+}
+
+func (OsFs) Open(name string) (File, error) {
+	return os.Open(preparePath(name))
+}
+
+func (OsFs) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	return os.OpenFile(preparePath(name), flag, perm)
+}
+
+func (OsFs) Mkdir(name string, perm os.FileMode) error {
+	return os.Mkdir(preparePath(name), perm)
+}
+
+func (OsFs) MkdirAll(name string, perm os.FileMode) error {
+	return os.MkdirAll(preparePath(name), perm)
+}
+
+func (OsFs) Rename(oldpath, newpath string) error {
+	return os.Rename(preparePath(oldpath), preparePath(newpath))
+}
+
+func (OsFs) Remove(name string) error {
+	return os.Remove(preparePath(name))
+}
+
+func (OsFs) Link(oldname, newname string) error {
+	return os.Link(preparePath(oldname), preparePath(newname))
+}
+
+// Symlink creates newname as a symbolic link to oldname. oldname is
+// stored verbatim as the link's target text and is intentionally not
+// passed through preparePath: it isn't a path this call resolves, just
+// the string a later Readlink will return.
+func (OsFs) Symlink(oldname, newname string) error {
+	return os.Symlink(oldname, preparePath(newname))
+}
+
+func (OsFs) Readlink(name string) (string, error) {
+	return os.Readlink(preparePath(name))
+}
+
+func (OsFs) Chtimes(name string, atime, mtime time.Time) error {
+	return os.Chtimes(preparePath(name), atime, mtime)
+}
+
+func (OsFs) ReadDir(name string) ([]os.FileInfo, error) {
+	entries, err := os.ReadDir(preparePath(name))
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]os.FileInfo, 0, len(entries))
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// Fs is the default Filesystem used by the package-level helper functions
+// in this package. Tests may swap it for a MemFs to avoid touching disk.
+var Fs Filesystem = NewOsFs()
+
+// liveFs is a Filesystem that forwards every call to the package-level Fs
+// variable, read at call time. It backs defaultFileUtil so that
+// reassigning Fs takes effect for the package-level helper functions
+// immediately -- NewFileUtil(Fs) would instead capture the Filesystem Fs
+// pointed to at package-init time, and never see later reassignments.
+type liveFs struct{}
+
+func (liveFs) Stat(name string) (os.FileInfo, error) { return Fs.Stat(name) }
+
+func (liveFs) Open(name string) (File, error) { return Fs.Open(name) }
+
+func (liveFs) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	return Fs.OpenFile(name, flag, perm)
+}
+
+func (liveFs) Mkdir(name string, perm os.FileMode) error { return Fs.Mkdir(name, perm) }
+
+func (liveFs) MkdirAll(name string, perm os.FileMode) error { return Fs.MkdirAll(name, perm) }
+
+func (liveFs) Rename(oldpath, newpath string) error { return Fs.Rename(oldpath, newpath) }
+
+func (liveFs) Remove(name string) error { return Fs.Remove(name) }
+
+func (liveFs) Link(oldname, newname string) error { return Fs.Link(oldname, newname) }
+
+func (liveFs) Symlink(oldname, newname string) error { return Fs.Symlink(oldname, newname) }
+
+func (liveFs) Readlink(name string) (string, error) { return Fs.Readlink(name) }
+
+func (liveFs) Chtimes(name string, atime, mtime time.Time) error {
+	return Fs.Chtimes(name, atime, mtime)
+}
+
+func (liveFs) ReadDir(name string) ([]os.FileInfo, error) { return Fs.ReadDir(name) }
+
+// FileUtil bundles the file helpers in this package behind a Filesystem,
+// so callers such as CDN storage, the piece writer or the download task
+// can inject a scoped filesystem, e.g. one chrooted to the task data dir,
+// instead of always operating on the real OS filesystem.
+type FileUtil struct {
+	Fs Filesystem
+}
+
+// NewFileUtil creates a FileUtil backed by the given Filesystem. A nil
+// Filesystem falls back to the package-level default Fs.
+func NewFileUtil(fs Filesystem) *FileUtil {
+	if fs == nil {
+		fs = Fs
+	}
+	return &FileUtil{Fs: fs}
+}
+
+// defaultFileUtil backs the package-level helper functions in file_util.go.
+// It is backed by liveFs, not Fs directly, so that tests reassigning the
+// package-level Fs variable (e.g. to a MemFs) are observed by those
+// functions too.
+var defaultFileUtil = NewFileUtil(liveFs{})
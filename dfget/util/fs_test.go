@@ -0,0 +1,119 @@
+/*
+ * Copyright 1999-2018 Alibaba Group.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package util
+
+import (
+	"os"
+	"testing"
+)
+
+func TestFileUtil_MemFs_CreateAndCopy(t *testing.T) {
+	fu := NewFileUtil(NewMemFs())
+
+	if err := fu.CreateDirectory("/data/task"); err != nil {
+		t.Fatalf("CreateDirectory() error = %v", err)
+	}
+	if !fu.IsDir("/data/task") {
+		t.Fatalf("IsDir() = false, want true")
+	}
+
+	src := "/data/task/piece1"
+	f, err := fu.OpenFile(src, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatalf("OpenFile() error = %v", err)
+	}
+	if _, err := f.Write([]byte("hello world")); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	f.Close()
+
+	if !fu.IsRegularFile(src) {
+		t.Fatalf("IsRegularFile() = false, want true")
+	}
+
+	dst := "/data/task/piece1.copy"
+	if err := fu.CopyFile(src, dst); err != nil {
+		t.Fatalf("CopyFile() error = %v", err)
+	}
+
+	got, err := fu.OpenFile(dst, os.O_RDONLY, 0)
+	if err != nil {
+		t.Fatalf("OpenFile() error = %v", err)
+	}
+	buf := make([]byte, 32)
+	n, _ := got.Read(buf)
+	if string(buf[:n]) != "hello world" {
+		t.Fatalf("copied content = %q, want %q", buf[:n], "hello world")
+	}
+}
+
+func TestFileUtil_MemFs_MoveAndMd5(t *testing.T) {
+	fu := NewFileUtil(NewMemFs())
+
+	src := "/src"
+	f, err := fu.OpenFile(src, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatalf("OpenFile() error = %v", err)
+	}
+	f.Write([]byte("content"))
+	f.Close()
+
+	md5 := fu.Md5Sum(src)
+	if md5 == "" {
+		t.Fatalf("Md5Sum() = \"\", want non-empty")
+	}
+
+	if err := fu.MoveFileAfterCheckMd5(src, "/dst", md5); err != nil {
+		t.Fatalf("MoveFileAfterCheckMd5() error = %v", err)
+	}
+	if fu.PathExist(src) {
+		t.Fatalf("PathExist(src) = true, want false after move")
+	}
+	if !fu.PathExist("/dst") {
+		t.Fatalf("PathExist(dst) = false, want true after move")
+	}
+}
+
+func TestFileUtil_DefaultUsesOsFs(t *testing.T) {
+	if _, ok := Fs.(*OsFs); !ok {
+		t.Fatalf("Fs = %T, want *OsFs", Fs)
+	}
+	if _, ok := defaultFileUtil.Fs.(liveFs); !ok {
+		t.Fatalf("defaultFileUtil.Fs = %T, want liveFs", defaultFileUtil.Fs)
+	}
+}
+
+// TestPackageLevelFuncs_ObserveReassignedFs guards against defaultFileUtil
+// capturing a one-time snapshot of Fs: swapping Fs for a MemFs must be
+// observed by the package-level helper functions immediately, since
+// that's the whole point of exposing Fs as reassignable.
+func TestPackageLevelFuncs_ObserveReassignedFs(t *testing.T) {
+	original := Fs
+	mem := NewMemFs()
+	Fs = mem
+	defer func() { Fs = original }()
+
+	if err := CreateDirectory("/swapped"); err != nil {
+		t.Fatalf("CreateDirectory() error = %v", err)
+	}
+	if !IsDir("/swapped") {
+		t.Fatalf("IsDir(/swapped) = false, want true")
+	}
+	if _, ok := mem.find("/swapped"); !ok {
+		t.Fatalf("CreateDirectory() did not reach the swapped-in MemFs")
+	}
+}
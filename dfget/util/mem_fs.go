@@ -0,0 +1,355 @@
+/*
+ * Copyright 1999-2018 Alibaba Group.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package util
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemFs is an in-memory Filesystem, useful for unit testing code that
+// depends on this package without touching the real disk. It is not
+// safe to share a MemFs across independent tests that expect a clean
+// slate: create a new one with NewMemFs per test instead.
+type MemFs struct {
+	mu      sync.Mutex
+	entries map[string]*memEntry
+}
+
+type memEntry struct {
+	name    string
+	dir     bool
+	mode    os.FileMode
+	modTime time.Time
+	data    []byte
+	// linkTarget holds the target text of a symlink entry (mode&os.ModeSymlink != 0).
+	linkTarget string
+}
+
+// NewMemFs creates an empty MemFs rooted at "/".
+func NewMemFs() *MemFs {
+	fs := &MemFs{entries: make(map[string]*memEntry)}
+	fs.entries["/"] = &memEntry{name: "/", dir: true, mode: os.ModeDir | 0755, modTime: time.Unix(0, 0)}
+	return fs
+}
+
+func cleanPath(name string) string {
+	name = filepath.ToSlash(name)
+	if !strings.HasPrefix(name, "/") {
+		name = "/" + name
+	}
+	return filepath.ToSlash(filepath.Clean(name))
+}
+
+func (fs *MemFs) find(name string) (*memEntry, bool) {
+	e, ok := fs.entries[cleanPath(name)]
+	return e, ok
+}
+
+func (fs *MemFs) Stat(name string) (os.FileInfo, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	e, ok := fs.find(name)
+	if !ok {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+	}
+	return e.fileInfo(), nil
+}
+
+func (fs *MemFs) Open(name string) (File, error) {
+	return fs.OpenFile(name, os.O_RDONLY, 0)
+}
+
+func (fs *MemFs) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	clean := cleanPath(name)
+	e, ok := fs.entries[clean]
+	if !ok {
+		if flag&os.O_CREATE == 0 {
+			return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+		}
+		parent := filepath.ToSlash(filepath.Dir(clean))
+		if p, ok := fs.entries[parent]; !ok || !p.dir {
+			return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+		}
+		e = &memEntry{name: clean, mode: perm, modTime: time.Unix(0, 0)}
+		fs.entries[clean] = e
+	}
+	if e.dir {
+		return nil, &os.PathError{Op: "open", Path: name, Err: fmt.Errorf("is a directory")}
+	}
+	if flag&os.O_TRUNC != 0 {
+		e.data = nil
+	}
+	f := &memFile{entry: e, fs: fs}
+	if flag&os.O_APPEND != 0 {
+		f.offset = int64(len(e.data))
+	}
+	f.writable = flag&(os.O_WRONLY|os.O_RDWR) != 0
+	return f, nil
+}
+
+func (fs *MemFs) Mkdir(name string, perm os.FileMode) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	clean := cleanPath(name)
+	if _, ok := fs.entries[clean]; ok {
+		return &os.PathError{Op: "mkdir", Path: name, Err: os.ErrExist}
+	}
+	parent := filepath.ToSlash(filepath.Dir(clean))
+	if p, ok := fs.entries[parent]; !ok || !p.dir {
+		return &os.PathError{Op: "mkdir", Path: name, Err: os.ErrNotExist}
+	}
+	fs.entries[clean] = &memEntry{name: clean, dir: true, mode: perm | os.ModeDir, modTime: time.Unix(0, 0)}
+	return nil
+}
+
+func (fs *MemFs) MkdirAll(name string, perm os.FileMode) error {
+	clean := cleanPath(name)
+	parts := strings.Split(strings.Trim(clean, "/"), "/")
+	cur := ""
+	for _, p := range parts {
+		if p == "" {
+			continue
+		}
+		cur += "/" + p
+		if err := fs.Mkdir(cur, perm); err != nil {
+			fs.mu.Lock()
+			_, exists := fs.entries[cur]
+			fs.mu.Unlock()
+			if !exists {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (fs *MemFs) Rename(oldpath, newpath string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	oldClean := cleanPath(oldpath)
+	newClean := cleanPath(newpath)
+	e, ok := fs.entries[oldClean]
+	if !ok {
+		return &os.PathError{Op: "rename", Path: oldpath, Err: os.ErrNotExist}
+	}
+	delete(fs.entries, oldClean)
+	e.name = newClean
+	fs.entries[newClean] = e
+	return nil
+}
+
+func (fs *MemFs) Remove(name string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	clean := cleanPath(name)
+	if _, ok := fs.entries[clean]; !ok {
+		return &os.PathError{Op: "remove", Path: name, Err: os.ErrNotExist}
+	}
+	delete(fs.entries, clean)
+	return nil
+}
+
+func (fs *MemFs) Link(oldname, newname string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	oldClean := cleanPath(oldname)
+	e, ok := fs.entries[oldClean]
+	if !ok {
+		return &os.PathError{Op: "link", Path: oldname, Err: os.ErrNotExist}
+	}
+	newClean := cleanPath(newname)
+	// MemFs does not distinguish hard links from copies: both entries share
+	// the same backing data slice, which is good enough for callers that
+	// only read through the link afterwards.
+	fs.entries[newClean] = &memEntry{name: newClean, mode: e.mode, modTime: e.modTime, data: e.data}
+	return nil
+}
+
+// Symlink creates newname as a symlink entry recording oldname as its
+// target text; unlike Link, no backing data is copied.
+func (fs *MemFs) Symlink(oldname, newname string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	newClean := cleanPath(newname)
+	parent := filepath.ToSlash(filepath.Dir(newClean))
+	if p, ok := fs.entries[parent]; !ok || !p.dir {
+		return &os.PathError{Op: "symlink", Path: newname, Err: os.ErrNotExist}
+	}
+	fs.entries[newClean] = &memEntry{
+		name:       newClean,
+		mode:       os.ModeSymlink | 0777,
+		modTime:    time.Unix(0, 0),
+		linkTarget: oldname,
+	}
+	return nil
+}
+
+func (fs *MemFs) Readlink(name string) (string, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	e, ok := fs.find(name)
+	if !ok {
+		return "", &os.PathError{Op: "readlink", Path: name, Err: os.ErrNotExist}
+	}
+	if e.mode&os.ModeSymlink == 0 {
+		return "", &os.PathError{Op: "readlink", Path: name, Err: fmt.Errorf("not a symlink")}
+	}
+	return e.linkTarget, nil
+}
+
+func (fs *MemFs) Chtimes(name string, atime, mtime time.Time) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	e, ok := fs.find(name)
+	if !ok {
+		return &os.PathError{Op: "chtimes", Path: name, Err: os.ErrNotExist}
+	}
+	e.modTime = mtime
+	return nil
+}
+
+func (fs *MemFs) ReadDir(name string) ([]os.FileInfo, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	clean := cleanPath(name)
+	dir, ok := fs.entries[clean]
+	if !ok || !dir.dir {
+		return nil, &os.PathError{Op: "readdir", Path: name, Err: os.ErrNotExist}
+	}
+	prefix := clean
+	if prefix != "/" {
+		prefix += "/"
+	}
+	var infos []os.FileInfo
+	for path, e := range fs.entries {
+		if path == clean || !strings.HasPrefix(path, prefix) {
+			continue
+		}
+		if strings.Contains(strings.TrimPrefix(path, prefix), "/") {
+			continue
+		}
+		infos = append(infos, e.fileInfo())
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+	return infos, nil
+}
+
+func (e *memEntry) fileInfo() os.FileInfo {
+	return memFileInfo{e: e}
+}
+
+// memFileInfo adapts a memEntry to os.FileInfo.
+type memFileInfo struct {
+	e *memEntry
+}
+
+func (i memFileInfo) Name() string       { return filepath.Base(i.e.name) }
+func (i memFileInfo) Size() int64        { return int64(len(i.e.data)) }
+func (i memFileInfo) Mode() os.FileMode  { return i.e.mode }
+func (i memFileInfo) ModTime() time.Time { return i.e.modTime }
+func (i memFileInfo) IsDir() bool        { return i.e.dir }
+func (i memFileInfo) Sys() interface{}   { return nil }
+
+// memFile adapts a memEntry to the File interface.
+type memFile struct {
+	entry    *memEntry
+	fs       *MemFs
+	offset   int64
+	writable bool
+}
+
+func (f *memFile) Read(p []byte) (int, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	if f.offset >= int64(len(f.entry.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, f.entry.data[f.offset:])
+	f.offset += int64(n)
+	return n, nil
+}
+
+func (f *memFile) ReadAt(p []byte, off int64) (int, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	if off >= int64(len(f.entry.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, f.entry.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (f *memFile) Write(p []byte) (int, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	end := f.offset + int64(len(p))
+	if end > int64(len(f.entry.data)) {
+		grown := make([]byte, end)
+		copy(grown, f.entry.data)
+		f.entry.data = grown
+	}
+	copy(f.entry.data[f.offset:end], p)
+	f.offset = end
+	f.entry.modTime = time.Unix(0, 0)
+	return len(p), nil
+}
+
+func (f *memFile) Seek(offset int64, whence int) (int64, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	switch whence {
+	case io.SeekStart:
+		f.offset = offset
+	case io.SeekCurrent:
+		f.offset += offset
+	case io.SeekEnd:
+		f.offset = int64(len(f.entry.data)) + offset
+	}
+	return f.offset, nil
+}
+
+func (f *memFile) Close() error {
+	return nil
+}
+
+func (f *memFile) Name() string {
+	return f.entry.name
+}
+
+func (f *memFile) Stat() (os.FileInfo, error) {
+	return f.entry.fileInfo(), nil
+}
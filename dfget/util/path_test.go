@@ -0,0 +1,95 @@
+/*
+ * Copyright 1999-2018 Alibaba Group.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// longPath builds a path under root with more than 300 characters of
+// nested directory components, mimicking a deeply nested CDN cache
+// layout such as <cachedir>/<task-hash-prefixes>/<task>/<piece>.
+func longPath(root string) string {
+	segment := strings.Repeat("a", 50)
+	parts := []string{root}
+	for len(filepath.Join(parts...)) < 300 {
+		parts = append(parts, segment)
+	}
+	return filepath.Join(append(parts, "piece")...)
+}
+
+func TestOsFs_LongPath_FullLifecycle(t *testing.T) {
+	root, err := os.MkdirTemp("", "dragonfly-long-path-")
+	if err != nil {
+		t.Fatalf("MkdirTemp() error = %v", err)
+	}
+	defer os.RemoveAll(root)
+
+	fu := NewFileUtil(NewOsFs())
+	src := longPath(root)
+
+	if err := fu.CreateDirectory(filepath.Dir(src)); err != nil {
+		t.Fatalf("CreateDirectory() error = %v", err)
+	}
+
+	f, err := fu.OpenFile(src, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatalf("OpenFile() error = %v", err)
+	}
+	if _, err := f.Write([]byte("hello long path")); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	f.Close()
+
+	if !fu.IsRegularFile(src) {
+		t.Fatalf("IsRegularFile() = false, want true")
+	}
+	if fu.Md5Sum(src) == "" {
+		t.Fatalf("Md5Sum() = \"\", want non-empty")
+	}
+
+	link := src + ".link"
+	if err := fu.Link(src, link); err != nil {
+		t.Fatalf("Link() error = %v", err)
+	}
+	if !fu.PathExist(link) {
+		t.Fatalf("PathExist(link) = false, want true")
+	}
+
+	cp := src + ".copy"
+	if err := fu.CopyFile(src, cp); err != nil {
+		t.Fatalf("CopyFile() error = %v", err)
+	}
+
+	moved := src + ".moved"
+	if err := fu.MoveFile(cp, moved); err != nil {
+		t.Fatalf("MoveFile() error = %v", err)
+	}
+
+	if err := fu.DeleteFile(moved); err != nil {
+		t.Fatalf("DeleteFile() error = %v", err)
+	}
+	if err := fu.DeleteFile(link); err != nil {
+		t.Fatalf("DeleteFile() error = %v", err)
+	}
+	if err := fu.DeleteFile(src); err != nil {
+		t.Fatalf("DeleteFile() error = %v", err)
+	}
+}
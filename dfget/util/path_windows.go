@@ -0,0 +1,72 @@
+//go:build windows
+// +build windows
+
+/*
+ * Copyright 1999-2018 Alibaba Group.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package util
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// reservedDeviceNames are the Windows device names that can't be used as a
+// file or directory name, with or without an extension.
+var reservedDeviceNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// preparePath converts p to Windows' extended-length path form
+// (`\\?\C:\...` or `\\?\UNC\server\share\...`) so the file helpers in this
+// package aren't limited to MAX_PATH (260 characters) -- a real problem
+// for deeply nested CDN cache layouts like
+// <cachedir>/<task-hash-prefixes>/<task>/<piece>. Paths that are already
+// extended-length are returned unchanged. Relative paths, and paths that
+// contain a reserved device name as a component, only have their
+// separators normalized and get no prefix: the latter lets the OS reject
+// them with its usual error instead of silently reinterpreting them once
+// the \\?\ prefix disables Win32 path normalization.
+func preparePath(p string) string {
+	if p == "" || strings.HasPrefix(p, `\\?\`) {
+		return p
+	}
+	p = filepath.FromSlash(p)
+	if !filepath.IsAbs(p) || hasReservedDeviceName(p) {
+		return p
+	}
+	if strings.HasPrefix(p, `\\`) {
+		return `\\?\UNC\` + strings.TrimPrefix(p, `\\`)
+	}
+	return `\\?\` + p
+}
+
+func hasReservedDeviceName(p string) bool {
+	for _, part := range strings.Split(p, `\`) {
+		name := part
+		if i := strings.IndexByte(name, '.'); i >= 0 {
+			name = name[:i]
+		}
+		if reservedDeviceNames[strings.ToUpper(name)] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,272 @@
+/*
+ * Copyright 1999-2018 Alibaba Group.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package util
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// PackTar walks srcDir and writes a tar archive of its contents to dst,
+// optionally gzip-compressed per opts.Compression. It returns a Manifest
+// enumerating every packed file with its size and Digest, so a downloader
+// can verify individual files without re-hashing the whole archive.
+func (fu *FileUtil) PackTar(srcDir string, dst string, opts PackOptions) (Manifest, error) {
+	if err := opts.Compression.validate(); err != nil {
+		return Manifest{}, err
+	}
+	algo := opts.DigestAlgorithm
+	if algo == "" {
+		algo = AlgorithmSHA256
+	}
+
+	out, err := fu.OpenFile(dst, os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0644)
+	if err != nil {
+		return Manifest{}, err
+	}
+	defer out.Close()
+
+	var w io.Writer = out
+	var gz *gzip.Writer
+	if opts.Compression == CompressionGzip {
+		gz = gzip.NewWriter(out)
+		w = gz
+	}
+	tw := tar.NewWriter(w)
+
+	var manifest Manifest
+	walkErr := fu.walkFs(srcDir, func(path string, info os.FileInfo) error {
+		rel, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+
+		link := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = fu.Fs.Readlink(path); err != nil {
+				return err
+			}
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = rel
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := fu.OpenFile(path, os.O_RDONLY, 0666)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		h, err := newHasher(algo)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(io.MultiWriter(tw, h), f); err != nil {
+			return fmt.Errorf("pack tar:%s error, %v", rel, err)
+		}
+		manifest.Entries = append(manifest.Entries, ManifestEntry{
+			Name:   rel,
+			Size:   info.Size(),
+			Digest: Digest{Algorithm: algo, Hex: fmt.Sprintf("%x", h.Sum(nil))},
+		})
+		return nil
+	})
+	if walkErr != nil {
+		return Manifest{}, walkErr
+	}
+	if err := tw.Close(); err != nil {
+		return Manifest{}, err
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			return Manifest{}, err
+		}
+	}
+	return manifest, nil
+}
+
+// UnpackTar extracts the tar archive src into dstDir, creating it if
+// necessary. A leading gzip member is detected and transparently
+// decompressed regardless of opts.Compression. Entry paths are validated
+// against Zip-Slip (absolute paths or ".." components are rejected); each
+// regular file's mode and mtime are preserved, directories get a default
+// mode with their mtime preserved.
+func (fu *FileUtil) UnpackTar(src string, dstDir string, opts UnpackOptions) error {
+	in, err := fu.OpenFile(src, os.O_RDONLY, 0666)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	r, err := maybeGunzip(in)
+	if err != nil {
+		return err
+	}
+
+	if err := fu.CreateDirectory(dstDir); err != nil {
+		return err
+	}
+	var dirs []*tar.Header
+	err = fu.WalkTar(r, func(hdr *tar.Header, entry io.Reader) error {
+		if hdr.Typeflag == tar.TypeDir {
+			dirs = append(dirs, hdr)
+		}
+		return fu.extractTarEntry(dstDir, hdr, entry)
+	})
+	if err != nil {
+		return err
+	}
+	// Extracting entries into a directory updates its mtime, so directory
+	// mtimes are set again once every entry is in place.
+	for _, hdr := range dirs {
+		name, _ := archiveSafeName(hdr.Name) // validated by extractTarEntry
+		if err := fu.Fs.Chtimes(filepath.Join(dstDir, name), hdr.ModTime, hdr.ModTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WalkTar streams the tar archive read from r, invoking walkFn once per
+// entry with bounded memory: walkFn's reader is only valid for the
+// duration of the call.
+func (fu *FileUtil) WalkTar(r io.Reader, walkFn func(hdr *tar.Header, r io.Reader) error) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("walk tar error, %v", err)
+		}
+		if err := walkFn(hdr, tr); err != nil {
+			return err
+		}
+	}
+}
+
+func (fu *FileUtil) extractTarEntry(dstDir string, hdr *tar.Header, r io.Reader) error {
+	name, err := archiveSafeName(hdr.Name)
+	if err != nil {
+		return err
+	}
+	target := filepath.Join(dstDir, name)
+
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		if err := fu.CreateDirectory(target); err != nil {
+			return err
+		}
+		return fu.Fs.Chtimes(target, hdr.ModTime, hdr.ModTime)
+	case tar.TypeSymlink:
+		if err := fu.CreateDirectory(filepath.Dir(target)); err != nil {
+			return err
+		}
+		return fu.Fs.Symlink(hdr.Linkname, target)
+	case tar.TypeReg:
+		if err := fu.CreateDirectory(filepath.Dir(target)); err != nil {
+			return err
+		}
+		f, err := fu.Fs.OpenFile(target, os.O_WRONLY|os.O_TRUNC|os.O_CREATE, os.FileMode(hdr.Mode))
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(f, r); err != nil {
+			f.Close()
+			return fmt.Errorf("unpack tar:%s error, %v", name, err)
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+		return fu.Fs.Chtimes(target, hdr.ModTime, hdr.ModTime)
+	default:
+		return fmt.Errorf("unpack tar:%s error, unsupported entry type %c", name, hdr.Typeflag)
+	}
+}
+
+// maybeGunzip peeks at r's first two bytes to detect a gzip member and,
+// if found, returns a reader that transparently decompresses it.
+func maybeGunzip(r io.Reader) (io.Reader, error) {
+	br := &peekReader{r: r}
+	magic, err := br.peek(2)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		return gzip.NewReader(br)
+	}
+	return br, nil
+}
+
+// peekReader lets maybeGunzip inspect the first bytes of r without
+// consuming them for whatever reads r afterwards.
+type peekReader struct {
+	r      io.Reader
+	buffed []byte
+}
+
+func (p *peekReader) peek(n int) ([]byte, error) {
+	p.buffed = make([]byte, n)
+	read, err := io.ReadFull(p.r, p.buffed)
+	p.buffed = p.buffed[:read]
+	return p.buffed, err
+}
+
+func (p *peekReader) Read(b []byte) (int, error) {
+	if len(p.buffed) > 0 {
+		n := copy(b, p.buffed)
+		p.buffed = p.buffed[n:]
+		return n, nil
+	}
+	return p.r.Read(b)
+}
+
+// PackTar walks srcDir and writes a tar archive of its contents to dst.
+func PackTar(srcDir string, dst string, opts PackOptions) (Manifest, error) {
+	return defaultFileUtil.PackTar(srcDir, dst, opts)
+}
+
+// UnpackTar extracts the tar archive src into dstDir.
+func UnpackTar(src string, dstDir string, opts UnpackOptions) error {
+	return defaultFileUtil.UnpackTar(src, dstDir, opts)
+}
+
+// WalkTar streams the tar archive read from r, invoking walkFn once per
+// entry.
+func WalkTar(r io.Reader, walkFn func(hdr *tar.Header, r io.Reader) error) error {
+	return defaultFileUtil.WalkTar(r, walkFn)
+}
@@ -0,0 +1,202 @@
+/*
+ * Copyright 1999-2018 Alibaba Group.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package util
+
+import (
+	"archive/tar"
+	"io"
+	"os"
+	"testing"
+)
+
+func TestFileUtil_PackUnpackTar_RoundTrip(t *testing.T) {
+	fu := NewFileUtil(NewOsFs())
+	srcDir, err := os.MkdirTemp("", "dragonfly-tar-src-")
+	if err != nil {
+		t.Fatalf("MkdirTemp() error = %v", err)
+	}
+	defer os.RemoveAll(srcDir)
+
+	if err := fu.CreateDirectory(srcDir + "/sub"); err != nil {
+		t.Fatalf("CreateDirectory() error = %v", err)
+	}
+	writeFile(t, fu, srcDir+"/a.txt", "hello")
+	writeFile(t, fu, srcDir+"/sub/b.txt", "world")
+
+	archive := srcDir + ".tar.gz"
+	manifest, err := fu.PackTar(srcDir, archive, PackOptions{Compression: CompressionGzip})
+	if err != nil {
+		t.Fatalf("PackTar() error = %v", err)
+	}
+	if len(manifest.Entries) != 2 {
+		t.Fatalf("len(manifest.Entries) = %d, want 2", len(manifest.Entries))
+	}
+
+	dstDir, err := os.MkdirTemp("", "dragonfly-tar-dst-")
+	if err != nil {
+		t.Fatalf("MkdirTemp() error = %v", err)
+	}
+	defer os.RemoveAll(dstDir)
+
+	if err := fu.UnpackTar(archive, dstDir, UnpackOptions{}); err != nil {
+		t.Fatalf("UnpackTar() error = %v", err)
+	}
+	if !fu.IsRegularFile(dstDir + "/a.txt") {
+		t.Fatalf("IsRegularFile(a.txt) = false, want true")
+	}
+	if !fu.IsRegularFile(dstDir + "/sub/b.txt") {
+		t.Fatalf("IsRegularFile(sub/b.txt) = false, want true")
+	}
+}
+
+func TestFileUtil_PackUnpackTar_Symlink(t *testing.T) {
+	fu := NewFileUtil(NewOsFs())
+	srcDir, err := os.MkdirTemp("", "dragonfly-tar-symlink-src-")
+	if err != nil {
+		t.Fatalf("MkdirTemp() error = %v", err)
+	}
+	defer os.RemoveAll(srcDir)
+
+	writeFile(t, fu, srcDir+"/real.txt", "hello")
+	if err := os.Symlink("real.txt", srcDir+"/link.txt"); err != nil {
+		t.Fatalf("Symlink() error = %v", err)
+	}
+
+	archive := srcDir + ".tar"
+	if _, err := fu.PackTar(srcDir, archive, PackOptions{}); err != nil {
+		t.Fatalf("PackTar() error = %v", err)
+	}
+
+	dstDir, err := os.MkdirTemp("", "dragonfly-tar-symlink-dst-")
+	if err != nil {
+		t.Fatalf("MkdirTemp() error = %v", err)
+	}
+	defer os.RemoveAll(dstDir)
+
+	if err := fu.UnpackTar(archive, dstDir, UnpackOptions{}); err != nil {
+		t.Fatalf("UnpackTar() error = %v", err)
+	}
+	target, err := os.Readlink(dstDir + "/link.txt")
+	if err != nil {
+		t.Fatalf("Readlink() error = %v", err)
+	}
+	if target != "real.txt" {
+		t.Fatalf("Readlink() = %q, want %q", target, "real.txt")
+	}
+}
+
+func TestFileUtil_PackUnpackTar_MemFs(t *testing.T) {
+	fu := NewFileUtil(NewMemFs())
+	if err := fu.CreateDirectory("/src/sub"); err != nil {
+		t.Fatalf("CreateDirectory() error = %v", err)
+	}
+	writeFile(t, fu, "/src/a.txt", "hello")
+	writeFile(t, fu, "/src/sub/b.txt", "world")
+
+	manifest, err := fu.PackTar("/src", "/out.tar", PackOptions{})
+	if err != nil {
+		t.Fatalf("PackTar() error = %v", err)
+	}
+	if len(manifest.Entries) != 2 {
+		t.Fatalf("len(manifest.Entries) = %d, want 2", len(manifest.Entries))
+	}
+
+	if err := fu.UnpackTar("/out.tar", "/dst", UnpackOptions{}); err != nil {
+		t.Fatalf("UnpackTar() error = %v", err)
+	}
+	if !fu.IsRegularFile("/dst/a.txt") {
+		t.Fatalf("IsRegularFile(/dst/a.txt) = false, want true")
+	}
+	if !fu.IsRegularFile("/dst/sub/b.txt") {
+		t.Fatalf("IsRegularFile(/dst/sub/b.txt) = false, want true")
+	}
+}
+
+func TestFileUtil_PackTar_RejectsUnsupportedCompression(t *testing.T) {
+	fu := NewFileUtil(NewMemFs())
+	if err := fu.CreateDirectory("/src"); err != nil {
+		t.Fatalf("CreateDirectory() error = %v", err)
+	}
+	writeFile(t, fu, "/src/a.txt", "hello")
+
+	if _, err := fu.PackTar("/src", "/out.tar", PackOptions{Compression: CompressionZstd}); err == nil {
+		t.Fatalf("PackTar() error = nil, want an error for unsupported compression")
+	}
+}
+
+func TestArchiveSafeName_RejectsEscapes(t *testing.T) {
+	cases := []struct {
+		name    string
+		wantErr bool
+	}{
+		{"a/b.txt", false},
+		{"/etc/passwd", true},
+		{"../../etc/passwd", true},
+		{"a/../../escape", true},
+	}
+	for _, c := range cases {
+		_, err := archiveSafeName(c.name)
+		if (err != nil) != c.wantErr {
+			t.Errorf("archiveSafeName(%q) error = %v, wantErr %v", c.name, err, c.wantErr)
+		}
+	}
+}
+
+func TestFileUtil_WalkTar(t *testing.T) {
+	fu := NewFileUtil(NewOsFs())
+	srcDir, err := os.MkdirTemp("", "dragonfly-tar-walk-")
+	if err != nil {
+		t.Fatalf("MkdirTemp() error = %v", err)
+	}
+	defer os.RemoveAll(srcDir)
+	writeFile(t, fu, srcDir+"/a.txt", "hello")
+
+	archive := srcDir + ".tar"
+	if _, err := fu.PackTar(srcDir, archive, PackOptions{}); err != nil {
+		t.Fatalf("PackTar() error = %v", err)
+	}
+
+	f, err := fu.OpenFile(archive, os.O_RDONLY, 0)
+	if err != nil {
+		t.Fatalf("OpenFile() error = %v", err)
+	}
+	defer f.Close()
+
+	var names []string
+	err = fu.WalkTar(f, func(hdr *tar.Header, r io.Reader) error {
+		names = append(names, hdr.Name)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WalkTar() error = %v", err)
+	}
+	if len(names) != 1 || names[0] != "a.txt" {
+		t.Fatalf("WalkTar() names = %v, want [a.txt]", names)
+	}
+}
+
+func writeFile(t *testing.T, fu *FileUtil, path string, content string) {
+	t.Helper()
+	f, err := fu.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatalf("OpenFile(%s) error = %v", path, err)
+	}
+	if _, err := f.Write([]byte(content)); err != nil {
+		t.Fatalf("Write(%s) error = %v", path, err)
+	}
+	f.Close()
+}
@@ -0,0 +1,231 @@
+/*
+ * Copyright 1999-2018 Alibaba Group.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package util
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// PackZip walks srcDir and writes a zip archive of its contents to dst.
+// opts.Compression selects per-entry Store (CompressionNone) or Deflate
+// (CompressionGzip, zip's only standard-library compressor) storage. Like
+// PackTar, a symlink is stored as a Store entry whose body is its target
+// text rather than being dereferenced, so a dangling symlink under
+// srcDir doesn't abort the pack; symlinks aren't given a Manifest entry,
+// matching PackTar. It returns a Manifest enumerating every packed
+// regular file with its size and Digest, so a downloader can verify
+// individual files without re-hashing the whole archive.
+func (fu *FileUtil) PackZip(srcDir string, dst string, opts PackOptions) (Manifest, error) {
+	if err := opts.Compression.validate(); err != nil {
+		return Manifest{}, err
+	}
+	algo := opts.DigestAlgorithm
+	if algo == "" {
+		algo = AlgorithmSHA256
+	}
+	method := zip.Store
+	if opts.Compression == CompressionGzip {
+		method = zip.Deflate
+	}
+
+	out, err := fu.OpenFile(dst, os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0644)
+	if err != nil {
+		return Manifest{}, err
+	}
+	defer out.Close()
+
+	zw := zip.NewWriter(out)
+
+	var manifest Manifest
+	walkErr := fu.walkFs(srcDir, func(path string, info os.FileInfo) error {
+		rel, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+
+		hdr, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		hdr.Name = rel
+		if info.IsDir() {
+			hdr.Name += "/"
+			_, err := zw.CreateHeader(hdr)
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			target, err := fu.Fs.Readlink(path)
+			if err != nil {
+				return err
+			}
+			hdr.Method = zip.Store
+			w, err := zw.CreateHeader(hdr)
+			if err != nil {
+				return err
+			}
+			_, err = io.WriteString(w, target)
+			return err
+		}
+		hdr.Method = method
+
+		w, err := zw.CreateHeader(hdr)
+		if err != nil {
+			return err
+		}
+
+		f, err := fu.OpenFile(path, os.O_RDONLY, 0666)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		h, err := newHasher(algo)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(io.MultiWriter(w, h), f); err != nil {
+			return fmt.Errorf("pack zip:%s error, %v", rel, err)
+		}
+		manifest.Entries = append(manifest.Entries, ManifestEntry{
+			Name:   rel,
+			Size:   info.Size(),
+			Digest: Digest{Algorithm: algo, Hex: fmt.Sprintf("%x", h.Sum(nil))},
+		})
+		return nil
+	})
+	if walkErr != nil {
+		return Manifest{}, walkErr
+	}
+	return manifest, zw.Close()
+}
+
+// UnpackZip extracts the zip archive src into dstDir, creating it if
+// necessary. Entry paths are validated against Zip-Slip (absolute paths
+// or ".." components are rejected); each regular file's mode and mtime
+// are preserved, directories get a default mode with their mtime
+// preserved. Unlike UnpackTar, zip requires random access to read its
+// central directory, so src must be a regular file rather than a stream.
+func (fu *FileUtil) UnpackZip(src string, dstDir string, opts UnpackOptions) error {
+	info, err := fu.Fs.Stat(src)
+	if err != nil {
+		return err
+	}
+	f, err := fu.OpenFile(src, os.O_RDONLY, 0666)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	ra, ok := f.(io.ReaderAt)
+	if !ok {
+		return fmt.Errorf("unpack zip:%s error, filesystem does not support random access", src)
+	}
+	zr, err := zip.NewReader(ra, info.Size())
+	if err != nil {
+		return fmt.Errorf("unpack zip:%s error, %v", src, err)
+	}
+
+	if err := fu.CreateDirectory(dstDir); err != nil {
+		return err
+	}
+	for _, entry := range zr.File {
+		if err := fu.extractZipEntry(dstDir, entry); err != nil {
+			return err
+		}
+	}
+	// Extracting entries into a directory updates its mtime, so directory
+	// mtimes are set again once every entry is in place.
+	for _, entry := range zr.File {
+		if !entry.FileInfo().IsDir() {
+			continue
+		}
+		name, _ := archiveSafeName(entry.Name) // validated by extractZipEntry
+		if err := fu.Fs.Chtimes(filepath.Join(dstDir, name), entry.Modified, entry.Modified); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (fu *FileUtil) extractZipEntry(dstDir string, entry *zip.File) error {
+	name, err := archiveSafeName(entry.Name)
+	if err != nil {
+		return err
+	}
+	target := filepath.Join(dstDir, name)
+
+	if entry.FileInfo().IsDir() {
+		if err := fu.CreateDirectory(target); err != nil {
+			return err
+		}
+		return fu.Fs.Chtimes(target, entry.Modified, entry.Modified)
+	}
+
+	if err := fu.CreateDirectory(filepath.Dir(target)); err != nil {
+		return err
+	}
+
+	if entry.Mode()&os.ModeSymlink != 0 {
+		r, err := entry.Open()
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		linkTarget, err := io.ReadAll(r)
+		if err != nil {
+			return fmt.Errorf("unpack zip:%s error, %v", name, err)
+		}
+		return fu.Fs.Symlink(string(linkTarget), target)
+	}
+
+	r, err := entry.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	w, err := fu.Fs.OpenFile(target, os.O_WRONLY|os.O_TRUNC|os.O_CREATE, entry.Mode())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		return fmt.Errorf("unpack zip:%s error, %v", name, err)
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return fu.Fs.Chtimes(target, entry.Modified, entry.Modified)
+}
+
+// PackZip walks srcDir and writes a zip archive of its contents to dst.
+func PackZip(srcDir string, dst string, opts PackOptions) (Manifest, error) {
+	return defaultFileUtil.PackZip(srcDir, dst, opts)
+}
+
+// UnpackZip extracts the zip archive src into dstDir.
+func UnpackZip(src string, dstDir string, opts UnpackOptions) error {
+	return defaultFileUtil.UnpackZip(src, dstDir, opts)
+}
@@ -0,0 +1,110 @@
+/*
+ * Copyright 1999-2018 Alibaba Group.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package util
+
+import (
+	"os"
+	"testing"
+)
+
+func TestFileUtil_PackUnpackZip_RoundTrip(t *testing.T) {
+	fu := NewFileUtil(NewOsFs())
+	srcDir, err := os.MkdirTemp("", "dragonfly-zip-src-")
+	if err != nil {
+		t.Fatalf("MkdirTemp() error = %v", err)
+	}
+	defer os.RemoveAll(srcDir)
+	writeFile(t, fu, srcDir+"/a.txt", "hello zip")
+
+	archive := srcDir + ".zip"
+	manifest, err := fu.PackZip(srcDir, archive, PackOptions{Compression: CompressionGzip})
+	if err != nil {
+		t.Fatalf("PackZip() error = %v", err)
+	}
+	if len(manifest.Entries) != 1 || manifest.Entries[0].Name != "a.txt" {
+		t.Fatalf("manifest.Entries = %+v, want one entry named a.txt", manifest.Entries)
+	}
+
+	dstDir, err := os.MkdirTemp("", "dragonfly-zip-dst-")
+	if err != nil {
+		t.Fatalf("MkdirTemp() error = %v", err)
+	}
+	defer os.RemoveAll(dstDir)
+
+	if err := fu.UnpackZip(archive, dstDir, UnpackOptions{}); err != nil {
+		t.Fatalf("UnpackZip() error = %v", err)
+	}
+	if !fu.IsRegularFile(dstDir + "/a.txt") {
+		t.Fatalf("IsRegularFile(a.txt) = false, want true")
+	}
+}
+
+func TestFileUtil_PackUnpackZip_Symlink(t *testing.T) {
+	fu := NewFileUtil(NewOsFs())
+	srcDir, err := os.MkdirTemp("", "dragonfly-zip-symlink-src-")
+	if err != nil {
+		t.Fatalf("MkdirTemp() error = %v", err)
+	}
+	defer os.RemoveAll(srcDir)
+
+	writeFile(t, fu, srcDir+"/real.txt", "hello world")
+	if err := os.Symlink("real.txt", srcDir+"/link.txt"); err != nil {
+		t.Fatalf("Symlink() error = %v", err)
+	}
+	if err := os.Symlink("missing.txt", srcDir+"/dangling.txt"); err != nil {
+		t.Fatalf("Symlink() error = %v", err)
+	}
+
+	archive := srcDir + ".zip"
+	manifest, err := fu.PackZip(srcDir, archive, PackOptions{})
+	if err != nil {
+		t.Fatalf("PackZip() error = %v", err)
+	}
+	// Symlinks aren't given a Manifest entry, matching PackTar: only
+	// real.txt should be listed.
+	if len(manifest.Entries) != 1 || manifest.Entries[0].Name != "real.txt" {
+		t.Fatalf("manifest.Entries = %+v, want one entry named real.txt", manifest.Entries)
+	}
+
+	dstDir, err := os.MkdirTemp("", "dragonfly-zip-symlink-dst-")
+	if err != nil {
+		t.Fatalf("MkdirTemp() error = %v", err)
+	}
+	defer os.RemoveAll(dstDir)
+
+	if err := fu.UnpackZip(archive, dstDir, UnpackOptions{}); err != nil {
+		t.Fatalf("UnpackZip() error = %v", err)
+	}
+	if target, err := os.Readlink(dstDir + "/link.txt"); err != nil || target != "real.txt" {
+		t.Fatalf("Readlink(link.txt) = (%q, %v), want (\"real.txt\", nil)", target, err)
+	}
+	if target, err := os.Readlink(dstDir + "/dangling.txt"); err != nil || target != "missing.txt" {
+		t.Fatalf("Readlink(dangling.txt) = (%q, %v), want (\"missing.txt\", nil)", target, err)
+	}
+}
+
+func TestFileUtil_PackZip_RejectsUnsupportedCompression(t *testing.T) {
+	fu := NewFileUtil(NewMemFs())
+	if err := fu.CreateDirectory("/src"); err != nil {
+		t.Fatalf("CreateDirectory() error = %v", err)
+	}
+	writeFile(t, fu, "/src/a.txt", "hello")
+
+	if _, err := fu.PackZip("/src", "/out.zip", PackOptions{Compression: CompressionZstd}); err == nil {
+		t.Fatalf("PackZip() error = nil, want an error for unsupported compression")
+	}
+}